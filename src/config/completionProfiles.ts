@@ -0,0 +1,126 @@
+/**
+ * Per-language completion profiles
+ */
+
+import * as path from 'path';
+import { CompletionProfile } from '../services/interfaces/ICompletionService';
+
+/**
+ * Profiles keyed by file extension (".go") or VS Code language id ("go")
+ */
+export type CompletionProfileMap = Record<string, CompletionProfile>;
+
+/**
+ * Key of the profile used when no extension or language mapping matches
+ */
+export const DEFAULT_PROFILE_KEY = 'default';
+
+/**
+ * Resolved profile together with the key it was matched on
+ */
+export interface ResolvedCompletionProfile {
+  key: string;
+  profile: CompletionProfile;
+}
+
+/**
+ * Resolves the profile for a file. The file extension takes precedence over
+ * the language id, and the "default" entry is used when neither matches.
+ */
+export function resolveCompletionProfile(
+  profiles: CompletionProfileMap | undefined,
+  languageId: string,
+  fileName: string
+): ResolvedCompletionProfile | undefined {
+  if (!profiles) {
+    return undefined;
+  }
+
+  const extension = path.extname(fileName).toLowerCase();
+  const candidates = [extension, languageId.toLowerCase(), DEFAULT_PROFILE_KEY].filter(Boolean);
+
+  for (const candidate of candidates) {
+    const key = Object.keys(profiles).find(k => k.toLowerCase() === candidate);
+    const profile = key ? sanitizeProfile(profiles[key]) : undefined;
+    if (key && profile) {
+      return { key, profile };
+    }
+  }
+
+  return undefined;
+}
+
+/**
+ * Lists the distinct models referenced by profiles
+ */
+export function getProfileModels(profiles: CompletionProfileMap | undefined): string[] {
+  const models = Object.values(profiles || {})
+    .flatMap(value => {
+      const profile = sanitizeProfile(value);
+      return [profile?.model, ...(profile?.fallbackModels || [])];
+    })
+    .filter((model): model is string => !!model);
+  return [...new Set(models)];
+}
+
+/**
+ * Lists every model the configuration refers to: the default model and
+ * the models named by profiles
+ */
+export function getConfiguredModels(
+  defaultModel: string | undefined,
+  profiles: CompletionProfileMap | undefined
+): string[] {
+  return [...new Set([defaultModel, ...getProfileModels(profiles)].filter((model): model is string => !!model))];
+}
+
+/**
+ * Drops fields with the wrong type so a malformed setting cannot break generation
+ */
+export function sanitizeProfile(value: unknown): CompletionProfile | undefined {
+  if (!value || typeof value !== 'object' || Array.isArray(value)) {
+    return undefined;
+  }
+
+  const raw = value as Record<string, unknown>;
+  const profile: CompletionProfile = {};
+
+  if (typeof raw.model === 'string' && raw.model.trim()) {
+    profile.model = raw.model.trim();
+  }
+  if (typeof raw.maxTokens === 'number' && raw.maxTokens > 0) {
+    profile.maxTokens = raw.maxTokens;
+  }
+  if (typeof raw.temperature === 'number' && raw.temperature >= 0 && raw.temperature <= 2) {
+    profile.temperature = raw.temperature;
+  }
+  if (typeof raw.topP === 'number' && raw.topP >= 0 && raw.topP <= 1) {
+    profile.topP = raw.topP;
+  }
+  if (typeof raw.seed === 'number' && Number.isInteger(raw.seed)) {
+    profile.seed = raw.seed;
+  }
+  if (typeof raw.contextWindow === 'number' && raw.contextWindow > 0) {
+    profile.contextWindow = raw.contextWindow;
+  }
+  if (typeof raw.promptTemplateFile === 'string' && raw.promptTemplateFile.trim()) {
+    profile.promptTemplateFile = raw.promptTemplateFile.trim();
+  }
+  if (raw.endpoint === 'generate' || raw.endpoint === 'chat') {
+    profile.endpoint = raw.endpoint;
+  }
+  if (typeof raw.stripMarkdown === 'boolean') {
+    profile.stripMarkdown = raw.stripMarkdown;
+  }
+  if (typeof raw.stripEcho === 'boolean') {
+    profile.stripEcho = raw.stripEcho;
+  }
+  if (Array.isArray(raw.stopSequences) && raw.stopSequences.every(s => typeof s === 'string')) {
+    profile.stopSequences = raw.stopSequences as string[];
+  }
+  if (Array.isArray(raw.fallbackModels) && raw.fallbackModels.every(m => typeof m === 'string' && m.trim())) {
+    profile.fallbackModels = (raw.fallbackModels as string[]).map(m => m.trim());
+  }
+
+  return profile;
+}
@@ -0,0 +1,75 @@
+/**
+ * Session-level generation parameter overrides
+ */
+
+import { Logger } from '../utils/logger';
+
+/**
+ * Generation parameters that can be overridden for the current session
+ */
+export interface GenerationOverrides {
+  temperature?: number;
+  topP?: number;
+}
+
+/**
+ * Accepted override names, including the Ollama spelling of each option
+ */
+const OVERRIDE_KEYS: Record<string, keyof GenerationOverrides> = {
+  temperature: 'temperature',
+  temp: 'temperature',
+  top_p: 'topP',
+  topp: 'topP'
+};
+
+/**
+ * Valid range for each override
+ */
+const OVERRIDE_RANGES: Record<keyof GenerationOverrides, [number, number]> = {
+  temperature: [0, 2],
+  topP: [0, 1]
+};
+
+/**
+ * Parses overrides written as "temperature=0.2 top_p=0.9". Unknown names
+ * and out-of-range values are skipped with a warning rather than rejected.
+ */
+export function parseGenerationOverrides(input: string): GenerationOverrides {
+  const overrides: GenerationOverrides = {};
+
+  for (const pair of input.split(/[\s,;]+/).filter(Boolean)) {
+    const [rawName, rawValue] = pair.split('=', 2);
+    // Own keys only, so names like "constructor" are unknown, not inherited
+    const name = rawName.trim().toLowerCase().replace(/-/g, '_');
+    const key = Object.hasOwn(OVERRIDE_KEYS, name) ? OVERRIDE_KEYS[name] : undefined;
+    if (!key) {
+      Logger.warn('GenerationOverrides', `Ignoring unknown override: ${rawName}`);
+      continue;
+    }
+
+    const value = Number(rawValue);
+    const [min, max] = OVERRIDE_RANGES[key];
+    if (rawValue === undefined || rawValue.trim() === '' || !Number.isFinite(value) || value < min || value > max) {
+      Logger.warn('GenerationOverrides', `Ignoring invalid ${rawName} value "${rawValue ?? ''}" (expected ${min}-${max})`);
+      continue;
+    }
+
+    overrides[key] = value;
+  }
+
+  return overrides;
+}
+
+/**
+ * Formats overrides in the form accepted by parseGenerationOverrides
+ */
+export function formatGenerationOverrides(overrides: GenerationOverrides): string {
+  const parts: string[] = [];
+  if (overrides.temperature !== undefined) {
+    parts.push(`temperature=${overrides.temperature}`);
+  }
+  if (overrides.topP !== undefined) {
+    parts.push(`top_p=${overrides.topP}`);
+  }
+  return parts.join(' ');
+}
@@ -0,0 +1,185 @@
+/**
+ * Per-project completion settings from a .ollama-copilot.yaml file
+ */
+
+import * as vscode from 'vscode';
+import * as fs from 'fs';
+import * as path from 'path';
+import { CompletionProfile } from '../services/interfaces/ICompletionService';
+import { CompletionProfileMap, sanitizeProfile } from './completionProfiles';
+import { parseSimpleYaml } from '../utils/simpleYaml';
+import { Logger } from '../utils/logger';
+import { isWithinWorkspace, resolveWorkspacePath } from '../utils/pathSecurity';
+
+/**
+ * File names searched for, in order, in each directory
+ */
+export const PROJECT_CONFIG_FILES = ['.ollama-copilot.yaml', '.ollama-copilot.yml'];
+
+/**
+ * Settings read from a project file: completion settings applied to every
+ * file below it, and profiles that work like ollama.completion.profiles
+ */
+export interface ProjectConfig {
+  file: string;
+  settings: CompletionProfile;
+  profiles: CompletionProfileMap;
+}
+
+/**
+ * Finds and parses project config files. A file applies to every document
+ * below its directory, and the nearest file wins. Only files inside the
+ * document's workspace folder are used, since those are the ones the
+ * watcher sees, and none at all until the workspace is trusted. Lookups
+ * and parsed files are cached until a config file is created, changed or
+ * deleted.
+ */
+export class ProjectConfigLoader implements vscode.Disposable {
+  private readonly locations = new Map<string, string | null>();
+  private readonly configs = new Map<string, ProjectConfig | null>();
+  private readonly watcher: vscode.FileSystemWatcher;
+  private readonly trustListener: vscode.Disposable;
+  private readonly changeEmitter = new vscode.EventEmitter<string>();
+
+  /**
+   * Fires with the path of a config file that was created or changed
+   */
+  readonly onDidChange = this.changeEmitter.event;
+
+  constructor() {
+    this.watcher = vscode.workspace.createFileSystemWatcher('**/.ollama-copilot.{yaml,yml}');
+    this.watcher.onDidCreate(uri => {
+      this.reset();
+      this.changeEmitter.fire(uri.fsPath);
+    });
+    this.watcher.onDidDelete(() => this.reset());
+    this.watcher.onDidChange(uri => {
+      this.configs.delete(uri.fsPath);
+      Logger.info('ProjectConfig', `Reloading ${uri.fsPath}`);
+      this.changeEmitter.fire(uri.fsPath);
+    });
+    this.trustListener = vscode.workspace.onDidGrantWorkspaceTrust(() => this.reset());
+  }
+
+  /**
+   * Gets the project config that applies to a document, if any
+   */
+  getConfig(fileName: string): ProjectConfig | undefined {
+    if (!path.isAbsolute(fileName) || !vscode.workspace.isTrusted) {
+      return undefined;
+    }
+
+    const folder = vscode.workspace.getWorkspaceFolder(vscode.Uri.file(fileName));
+    if (!folder) {
+      return undefined;
+    }
+
+    const file = this.findConfigFile(path.dirname(fileName), folder.uri.fsPath);
+    if (!file) {
+      return undefined;
+    }
+
+    if (!this.configs.has(file)) {
+      this.configs.set(file, this.load(file));
+    }
+    return this.configs.get(file) ?? undefined;
+  }
+
+  dispose(): void {
+    this.watcher.dispose();
+    this.trustListener.dispose();
+    this.changeEmitter.dispose();
+  }
+
+  private reset(): void {
+    this.locations.clear();
+    this.configs.clear();
+  }
+
+  /**
+   * Walks up from a directory to the nearest config file, stopping at the
+   * workspace folder root
+   */
+  private findConfigFile(directory: string, root: string): string | undefined {
+    const visited: string[] = [];
+    let current = directory;
+    let found: string | null = null;
+
+    for (;;) {
+      const cached = this.locations.get(current);
+      if (cached !== undefined) {
+        found = cached;
+        break;
+      }
+      visited.push(current);
+
+      const candidate = PROJECT_CONFIG_FILES
+        .map(name => path.join(current, name))
+        .find(file => fs.existsSync(file));
+      if (candidate) {
+        found = candidate;
+        break;
+      }
+
+      const parent = path.dirname(current);
+      if (parent === current || path.relative(root, current) === '') {
+        break;
+      }
+      current = parent;
+    }
+
+    visited.forEach(dir => this.locations.set(dir, found));
+    return found ?? undefined;
+  }
+
+  private load(file: string): ProjectConfig | null {
+    try {
+      const raw = parseSimpleYaml(fs.readFileSync(file, 'utf8'));
+      const { profiles, ...settings } = raw;
+      const config: ProjectConfig = {
+        file,
+        settings: this.resolvePaths(sanitizeProfile(settings) || {}, file),
+        profiles: {}
+      };
+
+      if (profiles && typeof profiles === 'object' && !Array.isArray(profiles)) {
+        for (const [key, value] of Object.entries(profiles)) {
+          const profile = sanitizeProfile(value);
+          if (profile) {
+            config.profiles[key] = this.resolvePaths(profile, file);
+          }
+        }
+      }
+
+      Logger.info('ProjectConfig', `Loaded ${file}`);
+      return config;
+    } catch (error) {
+      const message = error instanceof Error ? error.message : String(error);
+      Logger.error('ProjectConfig', `Invalid project config ${file}`, error);
+      vscode.window.showErrorMessage(`Ollama Copilot: invalid project config ${file}: ${message}. Using the global settings.`);
+      return null;
+    }
+  }
+
+  /**
+   * Resolves relative paths in a project file from the file's own directory.
+   * A template outside the workspace is dropped, so a checked-in file
+   * cannot make the extension read arbitrary files into prompts.
+   */
+  private resolvePaths(profile: CompletionProfile, file: string): CompletionProfile {
+    const template = profile.promptTemplateFile;
+    if (!template) {
+      return profile;
+    }
+
+    const resolved = path.isAbsolute(template) || template.startsWith('~')
+      ? resolveWorkspacePath(template)
+      : path.resolve(path.dirname(file), template);
+    if (!isWithinWorkspace(resolved)) {
+      Logger.warn('ProjectConfig', `Ignoring promptTemplateFile outside the workspace in ${file}: ${template}`);
+      const { promptTemplateFile, ...rest } = profile;
+      return rest;
+    }
+    return { ...profile, promptTemplateFile: resolved };
+  }
+}
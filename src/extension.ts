@@ -19,6 +19,8 @@ import {
   IConfigurationService,
   IModelService,
   ICompletionService,
+  PromptInspection,
+  IOllamaApiService,
   IValidationService,
   IMemoryMonitor,
   IPerformanceMonitor,
@@ -28,6 +30,11 @@ import {
 } from './services/interfaces';
 import { GlobalErrorBoundary } from './utils/GlobalErrorBoundary';
 import { Logger } from './utils/logger';
+import { CompletionProfileMap, getConfiguredModels } from './config/completionProfiles';
+import { benchmarkModels, formatBenchmarkTable } from './utils/modelBenchmark';
+import { buildConfigurationReport, formatConfigurationReport } from './validators/configurationReport';
+import { formatGenerationOverrides, parseGenerationOverrides } from './config/generationOverrides';
+import { DIInlineCompletionProvider, formatPromptInspection } from './inlineCompletionProvider/DIInlineCompletionProvider';
 
 /**
  * Global dependency injection container instance
@@ -66,6 +73,7 @@ export async function activate(context: vscode.ExtensionContext) {
   
   // Initialize logger
   Logger.initialize(outputChannel);
+  Logger.setLevel(vscode.workspace.getConfiguration('ollama').get<string>('logLevel', 'info'));
   
   try {
     // Create DI container
@@ -80,6 +88,10 @@ export async function activate(context: vscode.ExtensionContext) {
     outputChannel.appendLine('Initializing services...');
     await initializeServices(container);
     
+    // Persisted completions live in the extension's global storage by default
+    container.resolve<ICompletionService>(SERVICE_IDENTIFIERS.ICompletionService)
+      .setStorageDirectory(context.globalStorageUri.fsPath);
+    
     // Initialize global error boundary
     const errorHandler = container.resolve<IErrorHandlerService>(SERVICE_IDENTIFIERS.IErrorHandlerService);
     const errorBoundary = GlobalErrorBoundary.initialize(errorHandler, {
@@ -98,7 +110,8 @@ export async function activate(context: vscode.ExtensionContext) {
     const chatPanelFactory = createChatPanelFactory(container);
     
     // Register completion provider
-    completionProviderFactory.register(context);
+    const completionProvider = completionProviderFactory.create();
+    completionProviderFactory.register(context, completionProvider);
     
     // Register sidebar chat view
     outputChannel.appendLine('Registering sidebar chat view...');
@@ -106,11 +119,14 @@ export async function activate(context: vscode.ExtensionContext) {
     outputChannel.appendLine('✓ Sidebar chat view registered');
     
     // Register commands
-    registerCommands(context, container, chatPanelFactory);
+    registerCommands(context, container, chatPanelFactory, completionProvider);
     
     // Set up configuration change listener
     context.subscriptions.push(
       vscode.workspace.onDidChangeConfiguration(event => {
+        if (event.affectsConfiguration('ollama.logLevel')) {
+          Logger.setLevel(vscode.workspace.getConfiguration('ollama').get<string>('logLevel', 'info'));
+        }
         if (event.affectsConfiguration('ollama')) {
           handleConfigurationChange(container!);
         }
@@ -165,11 +181,17 @@ export async function activate(context: vscode.ExtensionContext) {
  * - Performance monitoring
  * - Resource management
  */
-function registerCommands(context: vscode.ExtensionContext, container: ServiceContainer, chatPanelFactory: ChatPanelFactory): void {
+function registerCommands(
+  context: vscode.ExtensionContext,
+  container: ServiceContainer,
+  chatPanelFactory: ChatPanelFactory,
+  completionProvider: DIInlineCompletionProvider
+): void {
   const modelService = container.resolve<IModelService>(SERVICE_IDENTIFIERS.IModelService);
   const completionService = container.resolve<ICompletionService>(SERVICE_IDENTIFIERS.ICompletionService);
   const validationService = container.resolve<IValidationService>(SERVICE_IDENTIFIERS.IValidationService);
   const configService = container.resolve<IConfigurationService>(SERVICE_IDENTIFIERS.IConfigurationService);
+  const apiService = container.resolve<IOllamaApiService>(SERVICE_IDENTIFIERS.IOllamaApiService);
   
   // Select default model command
   context.subscriptions.push(
@@ -208,6 +230,113 @@ function registerCommands(context: vscode.ExtensionContext, container: ServiceCo
     })
   );
   
+  // Session generation overrides command
+  context.subscriptions.push(
+    vscode.commands.registerCommand('ollama-copilot.setGenerationOverrides', async () => {
+      const input = await vscode.window.showInputBox({
+        prompt: 'Override generation parameters for this session (leave empty to clear)',
+        placeHolder: 'temperature=0.2 top_p=0.9',
+        value: formatGenerationOverrides(completionService.getGenerationOverrides())
+      });
+      
+      if (input === undefined) {
+        return;
+      }
+      
+      const overrides = parseGenerationOverrides(input);
+      completionService.setGenerationOverrides(overrides);
+      completionService.clearCache();
+      
+      const summary = formatGenerationOverrides(overrides);
+      vscode.window.showInformationMessage(
+        summary ? `Generation overrides: ${summary}` : 'Generation overrides cleared'
+      );
+    })
+  );
+  
+  // Completion prompt inspection command, built from the same context
+  // the provider would give a completion at the cursor
+  context.subscriptions.push(
+    vscode.commands.registerCommand('ollama-copilot.showCompletionPrompt', async () => {
+      if (!configService.get<boolean>('completion.inspectPrompt', false)) {
+        vscode.window.showInformationMessage('Prompt inspection is disabled. Enable "ollama.completion.inspectPrompt" to use it.');
+        return;
+      }
+      
+      const editor = vscode.window.activeTextEditor;
+      if (!editor) {
+        vscode.window.showWarningMessage('Place the cursor in a file to see its completion prompt.');
+        return;
+      }
+      
+      let inspection: PromptInspection;
+      try {
+        inspection = completionProvider.inspectPrompt(editor.document, editor.selection.active);
+      } catch (error) {
+        vscode.window.showErrorMessage(`Ollama Copilot: ${error instanceof Error ? error.message : String(error)}`);
+        return;
+      }
+      
+      const document = await vscode.workspace.openTextDocument({ content: formatPromptInspection(inspection), language: 'markdown' });
+      await vscode.window.showTextDocument(document);
+    })
+  );
+  
+  // Completion metrics command
+  context.subscriptions.push(
+    vscode.commands.registerCommand('ollama-copilot.showCompletionMetrics', async () => {
+      const metrics = completionService.getMetrics();
+      if (metrics === undefined) {
+        vscode.window.showInformationMessage('Completion metrics are disabled. Enable "ollama.metrics.enabled" to collect them.');
+        return;
+      }
+      
+      const document = await vscode.workspace.openTextDocument({ content: metrics, language: 'plaintext' });
+      await vscode.window.showTextDocument(document);
+    })
+  );
+  
+  // Model benchmark command
+  context.subscriptions.push(
+    vscode.commands.registerCommand('ollama-copilot.benchmarkModels', async () => {
+      const models = [...new Set([
+        ...getConfiguredModels(
+          configService.get<string>('defaultModel', ''),
+          configService.get<CompletionProfileMap>('completion.profiles', {})
+        ),
+        modelService.getSelectedModel()
+      ].filter(Boolean))];
+      
+      if (models.length === 0) {
+        vscode.window.showWarningMessage('No model is configured. Select a default model first.');
+        return;
+      }
+      
+      const format = await vscode.window.showQuickPick(['Table', 'JSON'], {
+        placeHolder: `Benchmark ${models.join(', ')} and show the results as`
+      });
+      if (!format) {
+        return;
+      }
+      
+      const results = await vscode.window.withProgress(
+        {
+          location: vscode.ProgressLocation.Notification,
+          title: 'Benchmarking models',
+          cancellable: true
+        },
+        (progress, token) => benchmarkModels(apiService, models, token, (model, prompt) => {
+          progress.report({ message: `${model}: ${prompt}` });
+        })
+      );
+      
+      const document = await vscode.workspace.openTextDocument(format === 'JSON'
+        ? { content: JSON.stringify(results, null, 2), language: 'json' }
+        : { content: formatBenchmarkTable(results), language: 'markdown' });
+      await vscode.window.showTextDocument(document);
+    })
+  );
+  
   // Search available models command
   context.subscriptions.push(
     vscode.commands.registerCommand('ollama-copilot.searchavailablemodels', async () => {
@@ -264,6 +393,30 @@ function registerCommands(context: vscode.ExtensionContext, container: ServiceCo
     })
   );
   
+  // Readiness check command
+  context.subscriptions.push(
+    vscode.commands.registerCommand('ollama-copilot.checkHealth', async () => {
+      const requiredModels = [
+        ...getConfiguredModels(
+          configService.get<string>('defaultModel', ''),
+          configService.get<CompletionProfileMap>('completion.profiles', {})
+        ),
+        modelService.getSelectedModel()
+      ].filter(Boolean);
+      
+      const health = await apiService.checkHealth(requiredModels);
+      Logger.info('Health', JSON.stringify(health, null, 2));
+      
+      if (health.status === 'ok') {
+        vscode.window.showInformationMessage(
+          `Ollama ${health.ollamaVersion} is ready at ${health.host}`
+        );
+      } else {
+        vscode.window.showErrorMessage(`Ollama is not ready: ${health.error}`);
+      }
+    })
+  );
+  
   // Memory management commands
   const memoryMonitor = container.tryResolve<IMemoryMonitor>(SERVICE_IDENTIFIERS.IMemoryMonitor);
   
@@ -314,6 +467,27 @@ function registerCommands(context: vscode.ExtensionContext, container: ServiceCo
   );
   
   // Validation commands
+  context.subscriptions.push(
+    vscode.commands.registerCommand('ollama-copilot.validateConfiguration', async () => {
+      const report = await vscode.window.withProgress(
+        { location: vscode.ProgressLocation.Notification, title: 'Validating configuration' },
+        () => buildConfigurationReport(apiService, modelService.getSelectedModel())
+      );
+      
+      const document = await vscode.workspace.openTextDocument({
+        content: formatConfigurationReport(report),
+        language: 'markdown'
+      });
+      await vscode.window.showTextDocument(document);
+      
+      if (report.passed) {
+        vscode.window.showInformationMessage('Configuration is valid');
+      } else {
+        vscode.window.showErrorMessage(`Configuration has ${report.checks.filter(check => !check.passed).length} problem(s)`);
+      }
+    })
+  );
+  
   context.subscriptions.push(
     vscode.commands.registerCommand('ollama-copilot.showValidationStats', () => {
       const stats = validationService.getValidationStats();
@@ -441,6 +615,9 @@ export async function deactivate() {
   
   try {
     if (container) {
+      // Let running completions finish before their services go away
+      await container.resolve<ICompletionService>(SERVICE_IDENTIFIERS.ICompletionService).shutdown();
+      
       // Dispose the container, which will dispose all services
       container.dispose();
       container = undefined;
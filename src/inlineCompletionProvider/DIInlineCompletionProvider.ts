@@ -4,13 +4,18 @@
 
 import * as vscode from 'vscode';
 import { Disposable } from '../utils/Disposable';
-import { ICompletionService, CompletionContext } from '../services/interfaces/ICompletionService';
+import { ICompletionService, CompletionContext, PromptInspection, RelatedFile } from '../services/interfaces/ICompletionService';
 import { IConfigurationService } from '../services/interfaces/IConfigurationService';
 import { IModelService } from '../services/interfaces/IModelService';
+import { detectLanguage } from './fileTypeDetectors';
+import { validateFilePath } from '../utils/pathSecurity';
 
 export class DIInlineCompletionProvider extends Disposable implements vscode.InlineCompletionItemProvider {
   private debounceTimeout: NodeJS.Timeout | null = null;
   private readonly DEBOUNCE_DELAY = 200; // ms
+  private readonly MAX_RELATED_FILES = 20;
+  private readonly MAX_RELATED_FILE_LENGTH = 20000; // chars
+  private readonly lastEdited = new Map<string, number>();
   
   constructor(
     private readonly completionService: ICompletionService,
@@ -28,6 +33,14 @@ export class DIInlineCompletionProvider extends Disposable implements vscode.Inl
         }
       }
     });
+    
+    // Remember edit recency so related files can be ranked by it
+    this.track(vscode.workspace.onDidChangeTextDocument(event => {
+      this.lastEdited.set(event.document.uri.toString(), Date.now());
+    }));
+    this.track(vscode.workspace.onDidCloseTextDocument(document => {
+      this.lastEdited.delete(document.uri.toString());
+    }));
   }
   
   async provideInlineCompletionItems(
@@ -57,9 +70,9 @@ export class DIInlineCompletionProvider extends Disposable implements vscode.Inl
         this.debounceTimeout = null;
         
         try {
-          // Check if a model is selected
+          // Check if a model is selected; echo mode runs without one
           const model = this.modelService.getSelectedModel();
-          if (!model) {
+          if (!model && !this.configService.get<boolean>('completion.echoMode', false)) {
             console.log('[DIInlineCompletionProvider] No model selected');
             vscode.window.showWarningMessage('No Ollama model selected. Please select a model first.');
             resolve(undefined);
@@ -68,12 +81,21 @@ export class DIInlineCompletionProvider extends Disposable implements vscode.Inl
           
           console.log(`[DIInlineCompletionProvider] Using model: ${model}`);
           
+          if (token.isCancellationRequested) {
+            resolve(undefined);
+            return;
+          }
+          
           // Build completion context
-          const completionContext = this.buildCompletionContext(document, position);
+          const completionContext = this.buildCompletionContext(document, position, token);
           
-          // Get completion from service
+          // Get completion from service; with several candidates the
+          // editor can cycle through them
           console.log('[DIInlineCompletionProvider] Requesting completion...');
-          const result = await this.completionService.getCompletion(completionContext);
+          const candidates = Math.max(1, this.configService.get<number>('completion.candidates', 1));
+          const results = candidates > 1
+            ? await this.completionService.getCompletions(completionContext, candidates)
+            : [await this.completionService.getCompletion(completionContext)];
           
           if (token.isCancellationRequested) {
             console.log('[DIInlineCompletionProvider] Request cancelled');
@@ -81,19 +103,20 @@ export class DIInlineCompletionProvider extends Disposable implements vscode.Inl
             return;
           }
           
-          if (!result || !result.text) {
+          const texts = results
+            .map(result => result?.text)
+            .filter((text): text is string => !!text);
+          if (texts.length === 0) {
             console.log('[DIInlineCompletionProvider] No result or empty text');
             resolve(undefined);
             return;
           }
           
-          console.log(`[DIInlineCompletionProvider] Got completion: ${result.text.substring(0, 50)}...`);
+          console.log(`[DIInlineCompletionProvider] Got ${texts.length} completion(s): ${texts[0].substring(0, 50)}...`);
           
-          // Create inline completion item
+          // Create inline completion items
           const range = new vscode.Range(position, position);
-          const item = new vscode.InlineCompletionItem(result.text, range);
-          
-          resolve([item]);
+          resolve(texts.map(text => new vscode.InlineCompletionItem(text, range)));
         } catch (error) {
           console.error('[DIInlineCompletionProvider] Error:', error);
           if (error instanceof Error) {
@@ -137,7 +160,8 @@ export class DIInlineCompletionProvider extends Disposable implements vscode.Inl
    */
   private buildCompletionContext(
     document: vscode.TextDocument,
-    position: vscode.Position
+    position: vscode.Position,
+    token?: vscode.CancellationToken
   ): CompletionContext {
     // Get prefix (everything before cursor)
     const prefix = document.getText(new vscode.Range(new vscode.Position(0, 0), position));
@@ -148,19 +172,63 @@ export class DIInlineCompletionProvider extends Disposable implements vscode.Inl
       new vscode.Position(document.lineCount - 1, document.lineAt(document.lineCount - 1).text.length)
     ));
     
-    // Detect indentation
-    const indentation = this.detectIndentation(document);
+    // Indent with the editor's settings for this document, falling back to
+    // what the file uses; a tab-indented cursor line wins in mixed files
+    const editor = vscode.window.visibleTextEditors.find(e => e.document === document);
+    const tabSize = typeof editor?.options.tabSize === 'number' ? editor.options.tabSize : 4;
+    const lineIndentation = document.lineAt(position.line).text.match(/^[ \t]*/)?.[0] || '';
+    let indentation = typeof editor?.options.insertSpaces === 'boolean'
+      ? (editor.options.insertSpaces ? ' '.repeat(tabSize) : '\t')
+      : this.detectIndentation(document);
+    if (lineIndentation.startsWith('\t')) {
+      indentation = '\t';
+    }
     
     return {
       document,
       position,
       prefix,
       suffix,
-      language: document.languageId,
-      indentation
+      language: detectLanguage(document.fileName, document.languageId, document.lineAt(0).text),
+      indentation,
+      tabSize,
+      relatedFiles: this.collectRelatedFiles(document),
+      token
     };
   }
   
+  /**
+   * Build the request a completion at a position would send, from the
+   * same context as a completion, without calling the model
+   */
+  inspectPrompt(document: vscode.TextDocument, position: vscode.Position): PromptInspection {
+    return this.completionService.inspectPrompt(this.buildCompletionContext(document, position));
+  }
+  
+  /**
+   * Collect other open workspace documents as cross-file context. Only
+   * files inside a trusted workspace are used, and never ones that look
+   * like secrets, such as .env files or keys, since the prompt may go to a
+   * remote host.
+   */
+  private collectRelatedFiles(document: vscode.TextDocument): RelatedFile[] {
+    if (!vscode.workspace.isTrusted) {
+      return [];
+    }
+    const current = document.uri.toString();
+    
+    return vscode.workspace.textDocuments
+      .filter(doc => doc.uri.scheme === 'file' && doc.uri.toString() !== current)
+      .filter(doc => validateFilePath(doc.fileName).isValid)
+      .slice(0, this.MAX_RELATED_FILES)
+      .map(doc => ({
+        fileName: doc.fileName,
+        languageId: doc.languageId,
+        content: doc.getText().substring(0, this.MAX_RELATED_FILE_LENGTH),
+        lastEdited: this.lastEdited.get(doc.uri.toString())
+      }));
+  }
+  
   /**
    * Detect indentation style from document
    */
@@ -211,4 +279,39 @@ export class DIInlineCompletionProvider extends Disposable implements vscode.Inl
       cacheStats: this.completionService.getCacheStats()
     };
   }
-}
\ No newline at end of file
+}
+
+/**
+ * Formats an inspected prompt as markdown, with the prompt verbatim
+ */
+export function formatPromptInspection(inspection: PromptInspection): string {
+  const block = (text: string, language = ''): string[] => {
+    // A fence longer than any backtick run in the text keeps it intact
+    const longest = Math.max(0, ...(text.match(/`+/g) || []).map(run => run.length));
+    const fence = '`'.repeat(Math.max(3, longest + 1));
+    return [fence + language, text, fence];
+  };
+  return [
+    '# Completion Prompt',
+    '',
+    `- Model: ${inspection.model}${inspection.profile ? ` (profile: ${inspection.profile})` : ''}`,
+    `- Endpoint: /api/${inspection.endpoint}${inspection.raw ? ', raw' : ''}`,
+    ...(inspection.fimFamily ? [`- Fill-in-the-middle: ${inspection.fimFamily}`] : []),
+    `- Completion type: ${inspection.completionType ?? '-'}`,
+    `- Prompt tokens: ~${inspection.promptTokens}`,
+    '',
+    '## Options',
+    '',
+    ...block(JSON.stringify(inspection.options, null, 2), 'json'),
+    '',
+    'The response is also cut at these stop sequences as it streams:',
+    '',
+    ...block(JSON.stringify(inspection.stopSequences), 'json'),
+    ...(inspection.system ? ['', '## System Prompt', '', ...block(inspection.system)] : []),
+    '',
+    '## Prompt',
+    '',
+    ...block(inspection.prompt),
+    ''
+  ].join('\n');
+}
@@ -1,4 +1,5 @@
 import * as vscode from "vscode";
+import { findCommentSyntax, getStringSyntax, StringSyntax } from "./languageSupport";
 
 /**
  * Functions for detecting the context of the cursor position
@@ -269,4 +270,88 @@ export function isNewPropertyLine(
     currentLine === '' ||
     (prevLine.includes(':') && !currentLine.includes(':'))
   );
-} 
\ No newline at end of file
+} 
+/**
+ * Infers the completion type from the cursor position: code after the
+ * cursor on the same line means a short inline suggestion, anything else
+ * a block completion
+ */
+export function inferCompletionType(lineText: string, character: number): "inline" | "block" {
+  return lineText.substring(character).trim() !== "" ? "inline" : "block";
+}
+
+/**
+ * What the cursor is inside: code, a comment or a string literal. For a
+ * block comment or a string, close is the delimiter that ends it; a line
+ * comment carries its marker instead.
+ */
+export interface CursorSyntax {
+  kind: "code" | "comment" | "string";
+  multiline: boolean;
+  close?: string;
+  marker?: string;
+}
+
+/**
+ * Finds whether the code before the cursor ends inside a comment or string
+ * literal by lexing it from the start of the file with the language's
+ * comment and quote delimiters. The lexer knows nothing else about the
+ * language, so constructs like regex literals can throw it off.
+ */
+export function scanCursorSyntax(prefix: string, language: string): CursorSyntax {
+  const comments = findCommentSyntax(language) ?? {};
+  const strings = getStringSyntax(language);
+
+  let i = 0;
+  while (i < prefix.length) {
+    if (comments.line && prefix.startsWith(comments.line, i)) {
+      const end = prefix.indexOf("\n", i);
+      if (end === -1) {
+        return { kind: "comment", multiline: false, marker: comments.line };
+      }
+      i = end + 1;
+      continue;
+    }
+
+    if (comments.blockStart && comments.blockEnd && prefix.startsWith(comments.blockStart, i)) {
+      const end = prefix.indexOf(comments.blockEnd, i + comments.blockStart.length);
+      if (end === -1) {
+        return { kind: "comment", multiline: true, close: comments.blockEnd };
+      }
+      i = end + comments.blockEnd.length;
+      continue;
+    }
+
+    const string = strings.find(syntax => prefix.startsWith(syntax.open, i));
+    if (string) {
+      const end = findStringEnd(prefix, i + string.open.length, string);
+      if (end === -1) {
+        return { kind: "string", multiline: string.multiline, close: string.close };
+      }
+      i = end;
+      continue;
+    }
+
+    i++;
+  }
+
+  return { kind: "code", multiline: false };
+}
+
+/**
+ * Position just after the end of a string literal, or -1 when the text
+ * ends inside it. A single-line string ends at the line break even when
+ * it was never closed.
+ */
+function findStringEnd(text: string, from: number, string: StringSyntax): number {
+  for (let i = from; i < text.length; i++) {
+    if (string.escapes && text[i] === "\\") {
+      i++;
+    } else if (text.startsWith(string.close, i)) {
+      return i + string.close.length;
+    } else if (!string.multiline && text[i] === "\n") {
+      return i;
+    }
+  }
+  return -1;
+}
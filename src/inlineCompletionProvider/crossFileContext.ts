@@ -0,0 +1,93 @@
+/**
+ * Cross-file context gathered from other open documents
+ */
+
+import * as path from 'path';
+import { RelatedFile } from '../services/interfaces/ICompletionService';
+import { formatComment } from './languageSupport';
+import { estimateTokens } from './tokenBudget';
+
+/**
+ * Lines that reference other modules in common languages
+ */
+const IMPORT_LINE_PATTERN = /^\s*(import|from|export .* from|require|#include|use|using|package)\b|require\(/;
+
+/**
+ * Ranks related files by relevance to the current file. Files imported by the
+ * current file come first, then files in the same language, then by most
+ * recent edit.
+ */
+export function rankRelatedFiles(
+  currentFileName: string,
+  currentText: string,
+  language: string,
+  relatedFiles: RelatedFile[]
+): RelatedFile[] {
+  const importLines = currentText
+    .split('\n')
+    .filter(line => IMPORT_LINE_PATTERN.test(line));
+
+  const scored = relatedFiles
+    .filter(file => file.fileName !== currentFileName && file.content.trim())
+    .map(file => {
+      const baseName = path.basename(file.fileName, path.extname(file.fileName));
+      const imported = baseName.length > 1 && importLines.some(line => line.includes(baseName));
+      const score = (imported ? 2 : 0) + (file.languageId === language ? 1 : 0);
+      return { file, score };
+    });
+
+  scored.sort((a, b) =>
+    b.score - a.score || (b.file.lastEdited ?? 0) - (a.file.lastEdited ?? 0)
+  );
+
+  return scored.map(entry => entry.file);
+}
+
+/**
+ * Builds a context block from related files that fits within the token budget.
+ * Snippets are trimmed at line boundaries and each one is headed by a comment
+ * naming its file.
+ */
+export function buildCrossFileContext(
+  currentFileName: string,
+  currentText: string,
+  language: string,
+  relatedFiles: RelatedFile[],
+  tokenBudget: number
+): string {
+  if (tokenBudget <= 0 || relatedFiles.length === 0) {
+    return '';
+  }
+
+  const ranked = rankRelatedFiles(currentFileName, currentText, language, relatedFiles);
+  const baseDir = path.dirname(currentFileName);
+  const blocks: string[] = [];
+  let remaining = tokenBudget;
+
+  for (const file of ranked) {
+    const header = formatComment(`Related file: ${path.relative(baseDir, file.fileName) || path.basename(file.fileName)}`, language);
+    let used = estimateTokens(header + '\n');
+    if (used >= remaining) {
+      break;
+    }
+
+    const lines: string[] = [];
+    for (const line of file.content.split('\n')) {
+      const cost = estimateTokens(line + '\n');
+      if (used + cost > remaining) {
+        break;
+      }
+      lines.push(line);
+      used += cost;
+    }
+
+    if (lines.length === 0) {
+      break;
+    }
+
+    blocks.push(`${header}\n${lines.join('\n')}\n`);
+    remaining -= used;
+  }
+
+  return blocks.length > 0 ? blocks.join('\n') + '\n' : '';
+}
@@ -35,4 +35,136 @@ export function isJSONFile(extension: string): boolean {
  */
 export function isSQLFile(extension: string): boolean {
   return ['sql', 'mysql', 'pgsql', 'sqlite'].includes(extension);
-} 
\ No newline at end of file
+} 
+/**
+ * Language used when neither the editor, the extension nor a shebang
+ * identifies the file
+ */
+export const GENERIC_LANGUAGE = 'plaintext';
+
+/**
+ * VS Code language ids keyed by file extension
+ */
+const EXTENSION_LANGUAGES: Record<string, string> = {
+  go: 'go',
+  ts: 'typescript',
+  mts: 'typescript',
+  cts: 'typescript',
+  tsx: 'typescriptreact',
+  js: 'javascript',
+  mjs: 'javascript',
+  cjs: 'javascript',
+  jsx: 'javascriptreact',
+  py: 'python',
+  pyw: 'python',
+  rb: 'ruby',
+  rs: 'rust',
+  java: 'java',
+  kt: 'kotlin',
+  kts: 'kotlin',
+  scala: 'scala',
+  swift: 'swift',
+  c: 'c',
+  h: 'c',
+  cc: 'cpp',
+  cpp: 'cpp',
+  cxx: 'cpp',
+  hpp: 'cpp',
+  cs: 'csharp',
+  php: 'php',
+  dart: 'dart',
+  lua: 'lua',
+  pl: 'perl',
+  pm: 'perl',
+  r: 'r',
+  ex: 'elixir',
+  exs: 'elixir',
+  hs: 'haskell',
+  sh: 'shellscript',
+  bash: 'shellscript',
+  zsh: 'shellscript',
+  ps1: 'powershell',
+  sql: 'sql',
+  yaml: 'yaml',
+  yml: 'yaml',
+  toml: 'toml',
+  json: 'json',
+  jsonc: 'jsonc',
+  md: 'markdown',
+  html: 'html',
+  htm: 'html',
+  css: 'css',
+  scss: 'scss',
+  less: 'less',
+  vue: 'vue',
+  svelte: 'svelte',
+  xml: 'xml'
+};
+
+/**
+ * VS Code language ids keyed by interpreter name, with version suffixes removed
+ */
+const SHEBANG_LANGUAGES: Record<string, string> = {
+  sh: 'shellscript',
+  bash: 'shellscript',
+  zsh: 'shellscript',
+  ksh: 'shellscript',
+  dash: 'shellscript',
+  python: 'python',
+  node: 'javascript',
+  deno: 'typescript',
+  bun: 'javascript',
+  'ts-node': 'typescript',
+  tsx: 'typescript',
+  ruby: 'ruby',
+  perl: 'perl',
+  php: 'php',
+  lua: 'lua',
+  rscript: 'r',
+  pwsh: 'powershell',
+  elixir: 'elixir'
+};
+
+/**
+ * Checks if a language id carries no information about the code
+ */
+export function isGenericLanguage(languageId: string | undefined): boolean {
+  return !languageId || languageId === GENERIC_LANGUAGE || languageId === 'plain';
+}
+
+/**
+ * Gets the language named by a shebang line such as "#!/usr/bin/env python3"
+ */
+export function getShebangLanguage(firstLine: string): string | undefined {
+  if (!firstLine.startsWith('#!')) {
+    return undefined;
+  }
+
+  const parts = firstLine.slice(2).trim().split(/\s+/);
+  let interpreter = parts[0]?.split('/').pop() || '';
+  if (interpreter === 'env') {
+    // Skip env flags like -S
+    interpreter = parts.slice(1).find(part => !part.startsWith('-')) || '';
+  }
+
+  const name = interpreter.toLowerCase().replace(/[\d.]+$/, '');
+  return SHEBANG_LANGUAGES[name];
+}
+
+/**
+ * Determines the language of a file. A specific editor language id wins,
+ * then the file extension, then the shebang of extensionless scripts, and
+ * unknown files fall back to the generic language.
+ */
+export function detectLanguage(fileName: string, languageId: string | undefined, firstLine: string): string {
+  if (!isGenericLanguage(languageId)) {
+    return languageId!;
+  }
+
+  const baseName = fileName.split(/[\\/]/).pop() || '';
+  const dot = baseName.lastIndexOf('.');
+  const extension = dot > 0 ? baseName.slice(dot + 1).toLowerCase() : '';
+  const byExtension = extension ? EXTENSION_LANGUAGES[extension] : undefined;
+
+  return byExtension || getShebangLanguage(firstLine) || GENERIC_LANGUAGE;
+}
@@ -0,0 +1,141 @@
+/**
+ * Language-specific syntax helpers for prompt construction
+ */
+
+/**
+ * Comment syntax for a language
+ */
+export interface CommentSyntax {
+  line?: string;
+  blockStart?: string;
+  blockEnd?: string;
+}
+
+const SLASH_COMMENTS: CommentSyntax = { line: '//', blockStart: '/*', blockEnd: '*/' };
+const HASH_COMMENTS: CommentSyntax = { line: '#' };
+const DASH_COMMENTS: CommentSyntax = { line: '--' };
+const HTML_COMMENTS: CommentSyntax = { blockStart: '<!--', blockEnd: '-->' };
+
+/**
+ * Comment syntax keyed by VS Code language id
+ */
+const COMMENT_SYNTAX: Record<string, CommentSyntax> = {
+  javascript: SLASH_COMMENTS,
+  javascriptreact: SLASH_COMMENTS,
+  typescript: SLASH_COMMENTS,
+  typescriptreact: SLASH_COMMENTS,
+  go: SLASH_COMMENTS,
+  rust: SLASH_COMMENTS,
+  java: SLASH_COMMENTS,
+  kotlin: SLASH_COMMENTS,
+  scala: SLASH_COMMENTS,
+  swift: SLASH_COMMENTS,
+  c: SLASH_COMMENTS,
+  cpp: SLASH_COMMENTS,
+  csharp: SLASH_COMMENTS,
+  dart: SLASH_COMMENTS,
+  php: SLASH_COMMENTS,
+  jsonc: SLASH_COMMENTS,
+  css: { blockStart: '/*', blockEnd: '*/' },
+  scss: SLASH_COMMENTS,
+  less: SLASH_COMMENTS,
+  python: HASH_COMMENTS,
+  ruby: HASH_COMMENTS,
+  perl: HASH_COMMENTS,
+  r: HASH_COMMENTS,
+  shellscript: HASH_COMMENTS,
+  powershell: HASH_COMMENTS,
+  yaml: HASH_COMMENTS,
+  toml: HASH_COMMENTS,
+  dockerfile: HASH_COMMENTS,
+  makefile: HASH_COMMENTS,
+  elixir: HASH_COMMENTS,
+  sql: DASH_COMMENTS,
+  lua: DASH_COMMENTS,
+  haskell: DASH_COMMENTS,
+  html: HTML_COMMENTS,
+  xml: HTML_COMMENTS,
+  markdown: HTML_COMMENTS,
+  vue: HTML_COMMENTS,
+  svelte: HTML_COMMENTS
+};
+
+/**
+ * Gets the comment syntax for a language, defaulting to C-style comments
+ */
+export function getCommentSyntax(language: string): CommentSyntax {
+  return COMMENT_SYNTAX[language] || SLASH_COMMENTS;
+}
+
+/**
+ * Gets the comment syntax a language is known to have. Plain text and
+ * unknown languages have none, so the "//" of a URL is not a comment.
+ */
+export function findCommentSyntax(language: string): CommentSyntax | undefined {
+  return Object.hasOwn(COMMENT_SYNTAX, language) ? COMMENT_SYNTAX[language] : undefined;
+}
+
+/**
+ * Formats a single line of text as a comment in the given language
+ */
+export function formatComment(text: string, language: string): string {
+  const syntax = getCommentSyntax(language);
+  if (syntax.line) {
+    return `${syntax.line} ${text}`;
+  }
+  return `${syntax.blockStart} ${text} ${syntax.blockEnd}`;
+}
+
+/**
+ * A string literal: its delimiters, whether it may span lines and whether
+ * a backslash escapes the next character
+ */
+export interface StringSyntax {
+  open: string;
+  close: string;
+  multiline: boolean;
+  escapes: boolean;
+}
+
+const quoted = (quote: string, multiline = false, escapes = true): StringSyntax =>
+  ({ open: quote, close: quote, multiline, escapes });
+
+const C_STRINGS = [quoted('"'), quoted("'")];
+const TRIPLE_QUOTED = [quoted('"""', true), quoted("'''", true)];
+
+/**
+ * String literals keyed by VS Code language id, longest delimiter first.
+ * Languages where a quote also appears outside strings, like Rust
+ * lifetimes, only list the delimiters that are unambiguous, and prose and
+ * markup languages have none.
+ */
+const STRING_SYNTAX: Record<string, StringSyntax[]> = {
+  javascript: [...C_STRINGS, quoted('`', true)],
+  javascriptreact: [...C_STRINGS, quoted('`', true)],
+  typescript: [...C_STRINGS, quoted('`', true)],
+  typescriptreact: [...C_STRINGS, quoted('`', true)],
+  go: [...C_STRINGS, quoted('`', true, false)],
+  rust: [quoted('"', true)],
+  haskell: [quoted('"')],
+  python: [...TRIPLE_QUOTED, ...C_STRINGS],
+  dart: [...TRIPLE_QUOTED, ...C_STRINGS],
+  java: [quoted('"""', true), ...C_STRINGS],
+  kotlin: [quoted('"""', true), ...C_STRINGS],
+  scala: [quoted('"""', true), ...C_STRINGS],
+  swift: [quoted('"""', true), ...C_STRINGS],
+  elixir: [quoted('"""', true), ...C_STRINGS],
+  html: [],
+  xml: [],
+  markdown: [],
+  vue: [],
+  svelte: [],
+  yaml: [],
+  plaintext: []
+};
+
+/**
+ * Gets the string literals for a language, defaulting to C-style quotes
+ */
+export function getStringSyntax(language: string): StringSyntax[] {
+  return STRING_SYNTAX[language] || C_STRINGS;
+}
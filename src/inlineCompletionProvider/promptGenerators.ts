@@ -1,6 +1,9 @@
 // promptGenerators.ts
 import * as vscode from "vscode";
 import { findVariablesInScope } from "./helpers";
+import { parsePromptTemplate, PromptTemplate } from "./promptTemplate";
+import { isGenericLanguage } from "./fileTypeDetectors";
+import { formatComment } from "./languageSupport";
 
 export function generatePrompt(
   fileContext: string,
@@ -38,34 +41,27 @@ RETURN ONLY THE CODE TO INSERT. NO EXPLANATIONS, NO <THINK> BLOCKS, NO COMMENTS,
   return prompt;
 }
 
-export function generatePromptFromContext(
-  context: {
-    prefix: string;
-    suffix: string;
-    currentLine: string;
-    language: string;
-  }
-): string {
-  const { prefix, suffix, currentLine, language } = context;
-  
-  // Limit context size to avoid overwhelming the model
-  const maxContextLength = 1000;
-  const prefixTruncated = prefix.length > maxContextLength 
-    ? '...' + prefix.slice(-maxContextLength) 
-    : prefix;
-  const suffixTruncated = suffix.length > maxContextLength 
-    ? suffix.slice(0, maxContextLength) + '...' 
-    : suffix;
-  
-  return `You are a code completion engine. Complete the ${language} code at the cursor position.
-
-Current line: "${currentLine}"
+/**
+ * Token cap for the code around the cursor in instruction prompts, which
+ * lose focus on the task when given too much code
+ */
+export const INSTRUCTION_CONTEXT_TOKENS = 500;
+
+/**
+ * Built-in instruction prompt, written in the same syntax as user templates
+ * so it can be copied as a starting point
+ */
+export const DEFAULT_PROMPT_TEMPLATE = `You are a code completion engine. Complete the {{if .Language}}{{.Language}} {{end}}code at the cursor position.
+
+{{if .RelatedContext}}Related code from other files:
+{{.RelatedContext}}
+{{end}}Current line: "{{.CurrentLine}}"
 
 Code before cursor:
-${prefixTruncated}
+{{.Prefix}}
 
 Code after cursor:
-${suffixTruncated}
+{{.Suffix}}
 
 RULES:
 1. Return ONLY the raw code to insert at the cursor position
@@ -84,4 +80,133 @@ Example of CORRECT response:
 fmt.Println("Hello")
 
 Now complete the code:`;
-}
\ No newline at end of file
+
+const defaultPromptTemplate = parsePromptTemplate(DEFAULT_PROMPT_TEMPLATE);
+
+export function generatePromptFromContext(
+  context: {
+    prefix: string;
+    suffix: string;
+    currentLine: string;
+    language: string;
+    fileName?: string;
+    relatedContext?: string;
+  },
+  template: PromptTemplate = defaultPromptTemplate
+): string {
+  // Prefix and suffix arrive already fitted to the token budget
+  return template.render({
+    Prefix: context.prefix,
+    Suffix: context.suffix,
+    // Unknown file types get a prompt without a language hint
+    Language: isGenericLanguage(context.language) ? '' : context.language,
+    Filename: context.fileName || '',
+    CurrentLine: context.currentLine,
+    RelatedContext: context.relatedContext || ''
+  });
+}
+
+/**
+ * Comment naming the file and its language, in the language's own comment
+ * syntax, for the top of the code in a prompt. Files without a known
+ * language get no header.
+ */
+export function generateFileHeader(fileName: string, language: string): string {
+  if (isGenericLanguage(language)) {
+    return '';
+  }
+  return `${formatComment(`file: ${fileName} (${language})`, language)}\n`;
+}
+
+/**
+ * System message for completions sent through the chat endpoint
+ */
+export const CHAT_SYSTEM_PROMPT = 'You are a code completion engine. Output only code, with no explanations and no markdown.';
+
+/**
+ * User message for the chat endpoint: the code around the cursor with the
+ * insertion point marked
+ */
+export const CHAT_PROMPT_TEMPLATE = `{{if .RelatedContext}}Related code from other files:
+{{.RelatedContext}}
+
+{{end}}Write the code that replaces <CURSOR> in {{if .Filename}}{{.Filename}}{{else}}this file{{end}}. Reply with only the code to insert.
+
+{{.Prefix}}<CURSOR>{{.Suffix}}`;
+
+export const chatPromptTemplate = parsePromptTemplate(CHAT_PROMPT_TEMPLATE);
+
+/**
+ * Fill-in-the-middle token layout for a model family
+ */
+export interface FimTemplate {
+  family: string;
+  prefixToken: string;
+  suffixToken: string;
+  middleToken: string;
+  stop: string[];
+}
+
+/**
+ * Known FIM templates, matched against the model name in order
+ */
+const FIM_TEMPLATES: Array<{ pattern: RegExp; template: FimTemplate }> = [
+  {
+    pattern: /codellama|code-llama/i,
+    template: {
+      family: 'codellama',
+      prefixToken: '<PRE> ',
+      suffixToken: ' <SUF>',
+      middleToken: ' <MID>',
+      stop: ['<EOT>', '<PRE>', '<SUF>', '<MID>']
+    }
+  },
+  {
+    pattern: /deepseek-coder/i,
+    template: {
+      family: 'deepseek-coder',
+      prefixToken: '<｜fim▁begin｜>',
+      suffixToken: '<｜fim▁hole｜>',
+      middleToken: '<｜fim▁end｜>',
+      stop: ['<｜fim▁begin｜>', '<｜fim▁hole｜>', '<｜fim▁end｜>', '<|EOT|>']
+    }
+  },
+  {
+    pattern: /qwen2\.5-coder|qwen2-coder|qwen3-coder|starcoder|codegemma|granite-code|stable-code/i,
+    template: {
+      family: 'fim-tokens',
+      prefixToken: '<|fim_prefix|>',
+      suffixToken: '<|fim_suffix|>',
+      middleToken: '<|fim_middle|>',
+      stop: ['<|endoftext|>', '<|fim_prefix|>', '<|fim_suffix|>', '<|fim_middle|>', '<|fim_pad|>', '<|file_separator|>']
+    }
+  }
+];
+
+/**
+ * Gets the FIM template for a model, or undefined if the model family
+ * is not known to support fill-in-the-middle
+ */
+export function getFimTemplate(model: string): FimTemplate | undefined {
+  const match = FIM_TEMPLATES.find(entry => entry.pattern.test(model));
+  return match?.template;
+}
+
+/**
+ * Wraps the code around the cursor in the model's FIM tokens
+ */
+export function generateFimPrompt(
+  context: {
+    prefix: string;
+    suffix: string;
+    relatedContext?: string;
+  },
+  template: FimTemplate
+): string {
+  const { prefix, suffix } = context;
+
+  // Related files go ahead of the prefix so the cursor context stays adjacent to the gap
+  const relatedContext = context.relatedContext || '';
+
+  return `${template.prefixToken}${relatedContext}${prefix}${template.suffixToken}${suffix}${template.middleToken}`;
+}
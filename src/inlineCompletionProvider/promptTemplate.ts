@@ -0,0 +1,161 @@
+/**
+ * User-defined prompt templates
+ *
+ * Templates use the Go text/template action syntax for the subset that
+ * prompts need: {{.Field}}, {{if .Field}}...{{else}}...{{end}}, and the
+ * {{- and -}} whitespace trim markers.
+ */
+
+/**
+ * Values available to a prompt template
+ */
+export interface PromptTemplateVars {
+  Prefix: string;
+  Suffix: string;
+  Language: string;
+  Filename: string;
+  CurrentLine: string;
+  RelatedContext: string;
+}
+
+export const PROMPT_TEMPLATE_FIELDS: ReadonlyArray<keyof PromptTemplateVars> = [
+  'Prefix',
+  'Suffix',
+  'Language',
+  'Filename',
+  'CurrentLine',
+  'RelatedContext'
+];
+
+type TemplateNode =
+  | { kind: 'text'; text: string }
+  | { kind: 'field'; field: keyof PromptTemplateVars }
+  | { kind: 'if'; field: keyof PromptTemplateVars; then: TemplateNode[]; otherwise: TemplateNode[] };
+
+/**
+ * Thrown when a template cannot be parsed, with the line of the bad action
+ */
+export class TemplateParseError extends Error {
+  constructor(message: string, readonly line: number) {
+    super(`line ${line}: ${message}`);
+    this.name = 'TemplateParseError';
+  }
+}
+
+/**
+ * A parsed template, ready to render
+ */
+export class PromptTemplate {
+  constructor(private readonly nodes: TemplateNode[]) {}
+
+  render(vars: PromptTemplateVars): string {
+    return renderNodes(this.nodes, vars);
+  }
+}
+
+/**
+ * Parses a template, failing on unknown fields and unbalanced blocks
+ */
+export function parsePromptTemplate(source: string): PromptTemplate {
+  const root: TemplateNode[] = [];
+  const stack: Array<{ node: Extract<TemplateNode, { kind: 'if' }>; inElse: boolean; line: number }> = [];
+  const current = (): TemplateNode[] => {
+    const top = stack[stack.length - 1];
+    return !top ? root : top.inElse ? top.node.otherwise : top.node.then;
+  };
+
+  const actionPattern = /\{\{(-\s)?\s*([\s\S]*?)\s*(\s-)?\}\}/g;
+  let position = 0;
+  let trimNext = false;
+  let match: RegExpExecArray | null;
+
+  while ((match = actionPattern.exec(source)) !== null) {
+    const line = source.slice(0, match.index).split('\n').length;
+    let text = source.slice(position, match.index);
+    if (trimNext) {
+      text = text.replace(/^\s+/, '');
+    }
+    if (match[1]) {
+      text = text.replace(/\s+$/, '');
+    }
+    if (text) {
+      current().push({ kind: 'text', text });
+    }
+    trimNext = !!match[3];
+    position = actionPattern.lastIndex;
+
+    const action = match[2];
+    const ifMatch = action.match(/^if\s+(.+)$/);
+    if (ifMatch) {
+      const node: Extract<TemplateNode, { kind: 'if' }> = {
+        kind: 'if',
+        field: parseField(ifMatch[1], line),
+        then: [],
+        otherwise: []
+      };
+      current().push(node);
+      stack.push({ node, inElse: false, line });
+    } else if (action === 'else') {
+      const top = stack[stack.length - 1];
+      if (!top || top.inElse) {
+        throw new TemplateParseError('unexpected {{else}}', line);
+      }
+      top.inElse = true;
+    } else if (action === 'end') {
+      if (!stack.pop()) {
+        throw new TemplateParseError('unexpected {{end}}', line);
+      }
+    } else if (action.startsWith('/*') && action.endsWith('*/')) {
+      // Comment
+    } else {
+      current().push({ kind: 'field', field: parseField(action, line) });
+    }
+  }
+
+  const unclosed = stack[stack.length - 1];
+  if (unclosed) {
+    throw new TemplateParseError('{{if}} is missing its {{end}}', unclosed.line);
+  }
+
+  let rest = source.slice(position);
+  if (trimNext) {
+    rest = rest.replace(/^\s+/, '');
+  }
+  if (rest.includes('{{')) {
+    throw new TemplateParseError('unclosed action', source.slice(0, source.lastIndexOf('{{')).split('\n').length);
+  }
+  if (rest) {
+    current().push({ kind: 'text', text: rest });
+  }
+
+  return new PromptTemplate(root);
+}
+
+function parseField(action: string, line: number): keyof PromptTemplateVars {
+  const match = action.trim().match(/^\.(\w+)$/);
+  if (!match) {
+    throw new TemplateParseError(`unsupported action {{${action}}}`, line);
+  }
+  const field = match[1] as keyof PromptTemplateVars;
+  if (!PROMPT_TEMPLATE_FIELDS.includes(field)) {
+    throw new TemplateParseError(
+      `unknown field .${match[1]} (available: ${PROMPT_TEMPLATE_FIELDS.map(f => '.' + f).join(', ')})`,
+      line
+    );
+  }
+  return field;
+}
+
+function renderNodes(nodes: TemplateNode[], vars: PromptTemplateVars): string {
+  let output = '';
+  for (const node of nodes) {
+    if (node.kind === 'text') {
+      output += node.text;
+    } else if (node.kind === 'field') {
+      output += vars[node.field];
+    } else {
+      output += renderNodes(vars[node.field] ? node.then : node.otherwise, vars);
+    }
+  }
+  return output;
+}
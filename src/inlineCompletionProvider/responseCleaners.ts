@@ -1,5 +1,6 @@
 // responseCleaners.ts
 import * as vscode from "vscode";
+import { CursorSyntax } from "./contextDetectors";
 
 export function cleanAIResponse(
   response: string,
@@ -67,25 +68,108 @@ export function getUniqueCompletion(completion: string, linePrefix: string): str
   return completion;
 }
 
-export function cleanCompletion(response: string): string {
-  if (!response.trim()) {return "";}
-  
-  // Remove all markdown code fences (including nested ones)
-  let cleaned = response;
+/**
+ * Number of trailing prefix lines checked for an echo
+ */
+const MAX_ECHO_LINES = 5;
+
+/**
+ * Shortest echo that is stripped, so short repeated tokens like "}" are kept
+ */
+const MIN_ECHO_LENGTH = 8;
+
+/**
+ * Number of non-blank lines after the cursor compared against a completion
+ */
+const MAX_SUFFIX_LINES = 5;
+
+/**
+ * Drops an opening ```lang fence line. While streaming, an unfinished first
+ * line that starts a fence is dropped too.
+ */
+export function stripLeadingFence(text: string): string {
+  if (!/^\s*```/.test(text)) {return text;}
+  const newline = text.indexOf('\n');
+  return newline === -1 ? '' : text.substring(newline + 1);
+}
+
+/**
+ * Strips an opening fence and language tag and a closing fence. Anything the
+ * model writes after the closing fence is prose and is dropped with it.
+ */
+export function stripMarkdownFences(text: string): string {
+  const body = stripLeadingFence(text);
+  const closing = body.search(/\r?\n?[ \t]*```/);
+  return closing === -1 ? body : body.substring(0, closing);
+}
+
+/**
+ * Removes a repeat of the code before the cursor from the start of a
+ * completion. The echo may begin anywhere in the last few prefix lines, as
+ * long as it starts on a token boundary, and whitespace is ignored when
+ * comparing, so a re-indented or reflowed repeat is still found. The
+ * longest overlap wins.
+ */
+export function stripPromptEcho(completion: string, prefix: string): string {
+  const tail = prefix.split(/\r?\n/).slice(-MAX_ECHO_LINES).join('\n');
+  const prefixChars = nonWhitespace(tail);
+  const completionChars = nonWhitespace(completion);
+  const prefixText = prefixChars.map(c => c.char).join('');
+  const completionText = completionChars.map(c => c.char).join('');
   
-  // Remove all occurrences of ```language at the start of lines
-  cleaned = cleaned.replace(/^```\w*$/gm, '');
+  for (let start = 0; prefixText.length - start >= MIN_ECHO_LENGTH; start++) {
+    if (!isTokenStart(prefixChars, start)) {
+      continue;
+    }
+    const overlap = prefixText.substring(start);
+    if (completionText.startsWith(overlap)) {
+      return completion.substring(completionChars[overlap.length - 1].index + 1);
+    }
+  }
   
-  // Remove inline ```language markers
-  cleaned = cleaned.replace(/```\w+/g, '');
+  return completion;
+}
+
+/**
+ * The non-whitespace characters of a text with their positions
+ */
+function nonWhitespace(text: string): Array<{ char: string; index: number }> {
+  const chars: Array<{ char: string; index: number }> = [];
+  for (let i = 0; i < text.length; i++) {
+    if (!/\s/.test(text[i])) {
+      chars.push({ char: text[i], index: i });
+    }
+  }
+  return chars;
+}
+
+/**
+ * Whether an echo may start at a character, so that a completion is never
+ * cut in the middle of an identifier it shares with the prefix
+ */
+function isTokenStart(chars: Array<{ char: string; index: number }>, position: number): boolean {
+  if (position === 0) {
+    return true;
+  }
+  const current = chars[position];
+  const previous = chars[position - 1];
+  return previous.index !== current.index - 1 || !/\w/.test(current.char) || !/\w/.test(previous.char);
+}
+
+export function cleanCompletion(response: string, stripFences = true): string {
+  if (!response.trim()) {return "";}
   
-  // Remove standalone ``` markers
-  cleaned = cleaned.replace(/```/g, '');
+  let cleaned = response;
   
-  // Extract code from markdown code blocks if they still exist
-  const codeBlockMatch = cleaned.match(/^```(?:\w+)?\n([\s\S]*?)\n```$/m);
-  if (codeBlockMatch) {
-    cleaned = codeBlockMatch[1];
+  if (stripFences) {
+    // Remove all occurrences of ```language at the start of lines
+    cleaned = cleaned.replace(/^```\w*$/gm, '');
+    
+    // Remove inline ```language markers
+    cleaned = cleaned.replace(/```\w+/g, '');
+    
+    // Remove standalone ``` markers
+    cleaned = cleaned.replace(/```/g, '');
   }
   
   // Split into lines for further processing
@@ -106,7 +190,7 @@ export function cleanCompletion(response: string): string {
     // Remove lines that look like markdown headers or explanations
     if (trimmedLine.startsWith('#') && !trimmedLine.startsWith('#!')) {return false;}
     if (trimmedLine.startsWith('---') || trimmedLine.startsWith('===')) {return false;}
-    if (trimmedLine.startsWith('```')) {return false;}
+    if (stripFences && trimmedLine.startsWith('```')) {return false;}
     
     // Remove explanatory comments (but keep TODO comments and code comments)
     if (trimmedLine.startsWith('//') && 
@@ -132,11 +216,204 @@ export function cleanCompletion(response: string): string {
   cleaned = cleanedLines.join('\n').trim();
   
   // Final safety check - if the response still contains markdown indicators, return empty
-  if (cleaned.includes('```')) {
+  if (stripFences && cleaned.includes('```')) {
     console.warn('[cleanCompletion] Response still contains markdown after cleaning:', cleaned);
     // Try one more aggressive cleanup
     cleaned = cleaned.split('```')[0].trim();
   }
   
   return cleaned;
-}
\ No newline at end of file
+}
+
+/**
+ * Removes trailing spaces and tabs from every line
+ */
+export function trimTrailingWhitespace(text: string): string {
+  return text.replace(/[ \t]+(?=\r?\n|$)/g, '');
+}
+
+/**
+ * Drops the unfinished last line of a cut-off generation. A single line is
+ * kept as is, since a short suggestion is better than none.
+ */
+export function dropPartialLine(text: string): string {
+  const lastNewline = text.lastIndexOf('\n');
+  return lastNewline > 0 ? text.substring(0, lastNewline) : text;
+}
+
+const CLOSERS: Record<string, string> = { ')': '(', ']': '[', '}': '{' };
+
+/**
+ * Positions of closing brackets with no opener earlier in the text, and the
+ * openers left unclosed at the end. Strings are skipped, and undefined is
+ * returned for mismatched brackets such as "(]" since the structure cannot
+ * be trusted then.
+ */
+function scanBrackets(text: string): { unmatched: Set<number>; open: string[] } | undefined {
+  const stack: string[] = [];
+  const unmatched = new Set<number>();
+  let quote: string | undefined;
+
+  for (let i = 0; i < text.length; i++) {
+    const char = text[i];
+    if (quote) {
+      if (char === '\\') {
+        i++;
+      } else if (char === quote || (char === '\n' && quote !== '`')) {
+        quote = undefined;
+      }
+    } else if (char === '"' || char === "'" || char === '`') {
+      quote = char;
+    } else if (char === '(' || char === '[' || char === '{') {
+      stack.push(char);
+    } else if (CLOSERS[char]) {
+      if (stack.length === 0) {
+        unmatched.add(i);
+      } else if (stack.pop() !== CLOSERS[char]) {
+        return undefined;
+      }
+    }
+  }
+
+  return { unmatched, open: stack };
+}
+
+/**
+ * Drops closing brackets at the end of a completion that the completion
+ * never opened when the code after the cursor starts with the same
+ * closers, so accepting it does not close a block twice. A closer is only
+ * dropped when it is surplus: the brackets the prefix leaves open are
+ * balanced against the completion's closers and the suffix's first, so
+ * "x)" after "foo(bar(" keeps its ")" even when the suffix is ")". Only an
+ * exact match of the trailing run is removed; anything else is left alone.
+ */
+export function dropDuplicateClosers(completion: string, suffix: string, prefix: string): string {
+  const unmatched = scanBrackets(completion)?.unmatched;
+  const open = scanBrackets(prefix)?.open;
+  if (!unmatched || unmatched.size === 0 || !open) {
+    return completion;
+  }
+
+  // Trailing closers the completion did not open, nearest the end first
+  const trailing: number[] = [];
+  for (let i = completion.length - 1; i >= 0; i--) {
+    if (/\s/.test(completion[i])) {
+      continue;
+    }
+    if (!unmatched.has(i)) {
+      break;
+    }
+    trailing.unshift(i);
+  }
+
+  const suffixClosers = (suffix.match(/^[\s)\]}]*/)?.[0] ?? '').replace(/\s/g, '');
+  const trailingClosers = trailing.map(i => completion[i]).join('');
+  const surplus = unmatched.size + suffixClosers.length - open.length;
+
+  for (let count = Math.min(trailing.length, suffixClosers.length, surplus); count > 0; count--) {
+    if (trailingClosers.slice(-count) === suffixClosers.slice(0, count)) {
+      return completion.substring(0, trailing[trailing.length - count]).trimEnd();
+    }
+  }
+
+  return completion;
+}
+
+/**
+ * Rewrites the indentation of every line after the first in the editor's
+ * style, so a completion indented with spaces in a tab-indented file, or
+ * the reverse, matches the document. Nesting is measured in the document's
+ * own indent unit relative to the cursor line's indentation, which is kept
+ * as is; columns left over that are not a whole level, such as the
+ * alignment of a JSDoc "*", stay spaces.
+ */
+export function reindentCompletion(
+  completion: string,
+  indentUnit: string,
+  tabSize = 4,
+  baseIndentation = ''
+): string {
+  const lines = completion.split('\n');
+  if (lines.length < 2 || !indentUnit) {
+    return completion;
+  }
+
+  const unitWidth = indentUnit === '\t' ? tabSize : indentUnit.length;
+  const width = (indent: string): number => indent.replace(/\t/g, ' '.repeat(tabSize)).length;
+  const convert = (columns: number): string =>
+    indentUnit.repeat(Math.floor(columns / unitWidth)) + ' '.repeat(columns % unitWidth);
+
+  return [lines[0], ...lines.slice(1).map(line => {
+    const leading = line.match(/^[ \t]*/)![0];
+    if (!leading || !line.trim()) {
+      return line;
+    }
+    const indentation = baseIndentation && leading.startsWith(baseIndentation)
+      ? baseIndentation + convert(width(leading.substring(baseIndentation.length)))
+      : convert(width(leading));
+    return indentation + line.substring(leading.length);
+  })].join('\n');
+}
+
+/**
+ * Cuts a completion where it starts repeating the lines after the cursor,
+ * so it only fills the gap up to the existing code. Lines only match with
+ * the same indentation, so a "}" closing an inner block is not taken for
+ * the outer one below the cursor; trailing whitespace and blank lines are
+ * ignored. A short match such as a lone "}" is only trusted when the
+ * completion has no block of its own left open, since it may be closing
+ * that block rather than repeating the suffix.
+ */
+export function truncateAtSuffix(completion: string, suffix: string): string {
+  const suffixLines = suffix.split(/\r?\n/).slice(1).map(line => line.trimEnd()).filter(Boolean).slice(0, MAX_SUFFIX_LINES);
+  if (suffixLines.length === 0) {
+    return completion;
+  }
+
+  const lines = completion.split('\n');
+  for (let i = 1; i < lines.length; i++) {
+    if (lines[i].trimEnd() !== suffixLines[0]) {
+      continue;
+    }
+
+    const rest = lines.slice(i).map(line => line.trimEnd()).filter(Boolean);
+    let matched = 0;
+    while (matched < rest.length && matched < suffixLines.length && rest[matched] === suffixLines[matched]) {
+      matched++;
+    }
+    const matchedLength = rest.slice(0, matched).map(line => line.trim()).join('').length;
+    const head = lines.slice(0, i).join('\n').trimEnd();
+    const substantial = matchedLength >= MIN_ECHO_LENGTH;
+
+    if (!substantial && (matched < rest.length || (scanBrackets(head)?.open.length ?? 1) > 0)) {
+      continue;
+    }
+    return head;
+  }
+
+  return completion;
+}
+
+/**
+ * Keeps a completion that starts inside a comment or string literal within
+ * it. Block comments and multi-line strings end at their closing
+ * delimiter, which is kept; a line comment continues only on lines that
+ * start with the same marker; a single-line string ends with the line.
+ */
+export function confineToSyntax(completion: string, syntax: CursorSyntax): string {
+  if (syntax.kind === 'code') {
+    return completion;
+  }
+  if (syntax.close && syntax.multiline) {
+    const end = completion.indexOf(syntax.close);
+    return end === -1 ? completion : completion.substring(0, end + syntax.close.length);
+  }
+
+  const lines = completion.split('\n');
+  const marker = syntax.marker;
+  let kept = 1;
+  while (marker && kept < lines.length && lines[kept].trimStart().startsWith(marker)) {
+    kept++;
+  }
+  return lines.slice(0, kept).join('\n').trimEnd();
+}
@@ -0,0 +1,83 @@
+/**
+ * Stop sequence defaults and truncation for completions
+ */
+
+/**
+ * Stop sequences used for every language
+ */
+const BASE_STOP_SEQUENCES = ['\n\n', '\r\n\r\n', '```', '\n```', '```\n'];
+
+/**
+ * Additional stop sequences per language, mostly top-level declarations
+ * that mean the model has moved past the block being completed
+ */
+const LANGUAGE_STOP_SEQUENCES: Record<string, string[]> = {
+  go: ['\nfunc ', '\ntype ', '\nvar ', '\nconst '],
+  python: ['\ndef ', '\nclass ', '\nif __name__', '\n@'],
+  javascript: ['\nfunction ', '\nclass ', '\nexport ', '\nmodule.exports'],
+  javascriptreact: ['\nfunction ', '\nclass ', '\nexport '],
+  typescript: ['\nfunction ', '\nclass ', '\nexport ', '\ninterface ', '\ntype '],
+  typescriptreact: ['\nfunction ', '\nclass ', '\nexport ', '\ninterface '],
+  rust: ['\nfn ', '\npub fn ', '\nimpl ', '\nstruct ', '\nenum ', '\nmod '],
+  java: ['\npublic class ', '\nclass ', '\ninterface '],
+  csharp: ['\npublic class ', '\nclass ', '\nnamespace '],
+  ruby: ['\ndef ', '\nclass ', '\nmodule '],
+  php: ['\nfunction ', '\nclass '],
+  shellscript: ['\nfunction '],
+  c: ['\nint main', '\nstatic ', '\n#include'],
+  cpp: ['\nint main', '\nclass ', '\nnamespace ', '\n#include']
+};
+
+/**
+ * Gets the default stop sequences for a language
+ */
+export function getDefaultStopSequences(language: string): string[] {
+  return [...BASE_STOP_SEQUENCES, ...(LANGUAGE_STOP_SEQUENCES[language] || [])];
+}
+
+/**
+ * Finds the earliest position of any stop sequence, or -1 when none match.
+ * Leading whitespace is skipped, so a completion that starts with a blank
+ * line is not cut to nothing by a "\n\n" stop. In single-line mode the
+ * first newline after non-whitespace text also stops, so leading newlines
+ * and indentation are kept.
+ */
+export function findStopSequence(text: string, stopSequences: string[], singleLine = false): number {
+  const content = text.search(/\S/);
+  if (content === -1) {
+    return -1;
+  }
+
+  let earliest = singleLine ? findLineEnd(text) : -1;
+  for (const stop of stopSequences) {
+    if (!stop) {continue;}
+    const index = text.indexOf(stop, content);
+    if (index !== -1 && (earliest === -1 || index < earliest)) {
+      earliest = index;
+    }
+  }
+  return earliest;
+}
+
+/**
+ * Position of the first newline that follows non-whitespace text, or -1
+ */
+function findLineEnd(text: string): number {
+  const content = text.search(/\S/);
+  return content === -1 ? -1 : text.indexOf('\n', content);
+}
+
+/**
+ * Truncates text at the first stop sequence
+ */
+export function truncateAtStopSequence(
+  text: string,
+  stopSequences: string[],
+  singleLine = false
+): { text: string; stopped: boolean } {
+  const index = findStopSequence(text, stopSequences, singleLine);
+  if (index === -1) {
+    return { text, stopped: false };
+  }
+  return { text: text.substring(0, index), stopped: true };
+}
@@ -0,0 +1,235 @@
+/**
+ * Token estimation and prompt budgeting
+ */
+
+import { formatComment } from './languageSupport';
+
+/**
+ * Average characters per token for source code across common tokenizers
+ */
+const CHARS_PER_TOKEN = 4;
+
+/**
+ * Largest share of the cursor budget given to the suffix. The prefix is what
+ * the model continues from, so it gets the rest.
+ */
+const SUFFIX_SHARE = 0.25;
+
+/**
+ * Lines that open a function, method or type in common languages
+ */
+const SIGNATURE_PATTERN = /^\s*(export\s+)?(default\s+)?(async\s+)?(function\b|def\s|func\s|fn\s|pub(\(\w+\))?\s+fn\s|class\s|interface\s|struct\s|impl\b|((public|private|protected|internal|static|override|virtual|abstract)\s+)+[\w<>[\],\s]*\w+\s*\()|^\s*(const|let|var)\s+\w+\s*=\s*(async\s+)?(\([^)]*\)|\w+)\s*=>/;
+
+/**
+ * Prefix and suffix after fitting them into a token budget
+ */
+export interface BudgetedContext {
+  prefix: string;
+  suffix: string;
+  trimmed: boolean;
+}
+
+/**
+ * Most lines kept before and after the cursor line
+ */
+export interface LineWindow {
+  linesBefore: number;
+  linesAfter: number;
+}
+
+/**
+ * Estimates the number of tokens in a piece of text
+ */
+export function estimateTokens(text: string): number {
+  return Math.ceil(text.length / CHARS_PER_TOKEN);
+}
+
+/**
+ * Computes how many tokens are left for the prompt once the generation
+ * is reserved out of the model's context window
+ */
+export function getPromptBudget(contextLength: number, maxTokens: number, overhead: number): number {
+  return Math.max(0, contextLength - maxTokens - overhead);
+}
+
+/**
+ * Smallest context window sized automatically
+ */
+const MIN_AUTO_CONTEXT_WINDOW = 512;
+
+/**
+ * Sizes the context window to a request: the prompt plus the generation,
+ * rounded up to the next power of two or, with a step, the next multiple
+ * of the step, and capped at the maximum
+ */
+export function sizeContextWindow(promptTokens: number, maxTokens: number, maximum: number, step = 0): number {
+  const needed = Math.max(1, promptTokens + maxTokens);
+  const size = step > 0
+    ? Math.ceil(needed / step) * step
+    : 2 ** Math.ceil(Math.log2(needed));
+  return Math.min(Math.max(size, MIN_AUTO_CONTEXT_WINDOW), maximum);
+}
+
+/**
+ * Trims the prefix and suffix so together they fit in the token budget and
+ * the optional line window. Whole lines nearest the cursor are kept, and
+ * when the prefix is cut the signature of the enclosing function is kept
+ * above an elision marker.
+ */
+export function fitToTokenBudget(
+  prefix: string,
+  suffix: string,
+  budget: number,
+  language: string,
+  window?: LineWindow
+): BudgetedContext {
+  const prefixLines = prefix.split('\n');
+  const minStart = window ? Math.max(0, prefixLines.length - 1 - window.linesBefore) : 0;
+  const windowedSuffix = window ? suffix.split('\n').slice(0, window.linesAfter + 1).join('\n') : suffix;
+  const windowedPrefixTokens = estimateTokens(prefixLines.slice(minStart).join('\n'));
+
+  if (windowedPrefixTokens + estimateTokens(windowedSuffix) <= budget) {
+    if (minStart === 0 && windowedSuffix === suffix) {
+      return { prefix, suffix, trimmed: false };
+    }
+    return {
+      prefix: fitPrefix(prefix, budget - estimateTokens(windowedSuffix), language, minStart),
+      suffix: windowedSuffix,
+      trimmed: true
+    };
+  }
+
+  const suffixBudget = Math.min(estimateTokens(windowedSuffix), Math.floor(budget * SUFFIX_SHARE));
+  const keptSuffix = takeLeadingLines(windowedSuffix, suffixBudget);
+  const keptPrefix = fitPrefix(prefix, budget - estimateTokens(keptSuffix), language, minStart);
+
+  return { prefix: keptPrefix, suffix: keptSuffix, trimmed: true };
+}
+
+/**
+ * Keeps the tail of the prefix from no earlier than minStart, adding the
+ * enclosing signature when it falls outside the kept lines
+ */
+function fitPrefix(prefix: string, budget: number, language: string, minStart = 0): string {
+  const lines = prefix.split('\n');
+  const start = Math.max(findTrailingStart(lines, budget), minStart);
+  if (start === 0) {
+    return prefix;
+  }
+
+  const signatureIndex = findEnclosingSignature(lines, start);
+  if (signatureIndex === -1) {
+    return takeTrailingLines(lines, start, budget);
+  }
+
+  const header = `${lines[signatureIndex]}\n${indentOf(lines[signatureIndex])}${formatComment('...', language)}\n`;
+  const remaining = budget - estimateTokens(header);
+  if (remaining <= 0) {
+    return takeTrailingLines(lines, start, budget);
+  }
+
+  const bodyStart = Math.max(findTrailingStart(lines, remaining), signatureIndex + 1, minStart);
+  return header + takeTrailingLines(lines, bodyStart, remaining);
+}
+
+/**
+ * Finds the first line index such that the lines from it to the end fit the
+ * budget. The last line (the cursor line) is always included.
+ */
+function findTrailingStart(lines: string[], budget: number): number {
+  let used = estimateTokens(lines[lines.length - 1]);
+  let start = lines.length - 1;
+
+  while (start > 0) {
+    const cost = estimateTokens(lines[start - 1] + '\n');
+    if (used + cost > budget) {
+      break;
+    }
+    used += cost;
+    start--;
+  }
+
+  return start;
+}
+
+/**
+ * Joins lines from start, clipping the cursor line itself only when it
+ * alone exceeds the budget
+ */
+function takeTrailingLines(lines: string[], start: number, budget: number): string {
+  const text = lines.slice(start).join('\n');
+  const maxChars = Math.max(0, budget) * CHARS_PER_TOKEN;
+  return text.length > maxChars ? text.slice(codePointBoundary(text, text.length - maxChars, 1)) : text;
+}
+
+/**
+ * Keeps whole lines from the start of the suffix that fit the budget
+ */
+function takeLeadingLines(suffix: string, budget: number): string {
+  const lines = suffix.split('\n');
+  const maxChars = Math.max(0, budget) * CHARS_PER_TOKEN;
+
+  if (lines[0].length > maxChars) {
+    return lines[0].slice(0, codePointBoundary(lines[0], maxChars, -1));
+  }
+
+  let used = estimateTokens(lines[0]);
+  let end = 1;
+  while (end < lines.length) {
+    const cost = estimateTokens('\n' + lines[end]);
+    if (used + cost > budget) {
+      break;
+    }
+    used += cost;
+    end++;
+  }
+
+  return lines.slice(0, end).join('\n');
+}
+
+/**
+ * Finds the nearest signature line above the kept window that is indented
+ * less than the code it would enclose
+ */
+function findEnclosingSignature(lines: string[], start: number): number {
+  const firstKept = lines.slice(start).find(line => line.trim());
+  if (firstKept === undefined) {
+    return -1;
+  }
+
+  let indent = indentOf(firstKept).length;
+  for (let i = start - 1; i >= 0; i--) {
+    const line = lines[i];
+    if (!line.trim()) {
+      continue;
+    }
+    const lineIndent = indentOf(line).length;
+    if (lineIndent < indent || (lineIndent === 0 && indent === 0)) {
+      if (SIGNATURE_PATTERN.test(line)) {
+        return i;
+      }
+      indent = Math.min(indent, lineIndent);
+      if (indent === 0 && lineIndent === 0) {
+        // A top-level non-signature line closes the search
+        return -1;
+      }
+    }
+  }
+
+  return -1;
+}
+
+/**
+ * Moves a cut position off the middle of a surrogate pair, forward or
+ * backward, so clipping never splits a character
+ */
+function codePointBoundary(text: string, index: number, direction: 1 | -1): number {
+  const code = text.charCodeAt(index);
+  const previous = text.charCodeAt(index - 1);
+  const splitsPair = code >= 0xdc00 && code <= 0xdfff && previous >= 0xd800 && previous <= 0xdbff;
+  return splitsPair ? index + direction : index;
+}
+
+function indentOf(line: string): string {
+  return line.match(/^\s*/)?.[0] || '';
+}
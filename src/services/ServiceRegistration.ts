@@ -2,11 +2,13 @@
  * Service registration helper for the dependency injection container
  */
 
+import * as vscode from 'vscode';
 import { IServiceContainer, SERVICE_IDENTIFIERS } from '../di';
 import * as implementations from './implementations';
 import { ResourceManager } from './ResourceManager';
 import { MemoryMonitor } from '../utils/memoryMonitor';
 import { RateLimiter } from '../utils/RateLimiter';
+import { getConfiguredModels } from '../config/completionProfiles';
 
 /**
  * Register all services in the container
@@ -70,7 +72,6 @@ export function registerServices(container: IServiceContainer): void {
     (c) => new implementations.CompletionService(
       c.resolve(SERVICE_IDENTIFIERS.IOllamaApiService),
       c.resolve(SERVICE_IDENTIFIERS.IModelService),
-      c.resolve(SERVICE_IDENTIFIERS.ICacheService),
       c.resolve(SERVICE_IDENTIFIERS.IConfigurationService)
     )
   );
@@ -134,18 +135,53 @@ export function registerServices(container: IServiceContainer): void {
  * Initialize services that need async initialization
  */
 export async function initializeServices(container: IServiceContainer): Promise<void> {
-  // Initialize model service with default model
   const modelService = container.tryResolve<any>(SERVICE_IDENTIFIERS.IModelService);
+  const configService = container.tryResolve<any>(SERVICE_IDENTIFIERS.IConfigurationService);
+  
+  // Pull configured models that are missing in the background, so a slow
+  // or failed pull never holds up activation
+  const pulled: Promise<void> = configService?.get('autoPullModels', false) && typeof modelService?.ensureModelsAvailable === 'function'
+    ? pullMissingModels(modelService, configService)
+    : Promise.resolve();
+  
+  // Initialize model service with default model
   if (modelService && typeof modelService.initializeDefaultModel === 'function') {
     await modelService.initializeDefaultModel();
   }
   
+  // Load the model in the background so the first completion is fast,
+  // once a pull that may be fetching it has finished. The completion
+  // service loads it with the context window completions will use.
+  const completionService = container.tryResolve<any>(SERVICE_IDENTIFIERS.ICompletionService);
+  if (configService?.get('warmUpOnStartup', false) && typeof completionService?.warmUpModel === 'function') {
+    void pulled.then(() => completionService.warmUpModel());
+  }
+  
   // Validate configuration
-  const configService = container.tryResolve<any>(SERVICE_IDENTIFIERS.IConfigurationService);
   if (configService && typeof configService.validate === 'function') {
     const result = await configService.validate();
     if (!result.isValid) {
       console.warn('Configuration validation errors:', result.errors);
     }
   }
-}
\ No newline at end of file
+}
+
+/**
+ * Pulls missing configured models behind a progress notification. A
+ * failure, timeout or unreachable Ollama is reported to the user and
+ * never rejects.
+ */
+async function pullMissingModels(modelService: any, configService: any): Promise<void> {
+  const models = getConfiguredModels(
+    configService.get('defaultModel', ''),
+    configService.get('completion.profiles', {})
+  );
+  try {
+    await vscode.window.withProgress(
+      { location: vscode.ProgressLocation.Notification, title: 'Ollama Copilot: checking configured models' },
+      progress => modelService.ensureModelsAvailable(models, configService.get('autoPullTimeout', 600000), progress)
+    );
+  } catch (error) {
+    vscode.window.showErrorMessage(`Ollama Copilot: ${error instanceof Error ? error.message : String(error)}`);
+  }
+}
@@ -18,7 +18,7 @@ export class CompletionProviderFactory {
   /**
    * Create a completion provider
    */
-  create(): vscode.InlineCompletionItemProvider {
+  create(): DIInlineCompletionProvider {
     // Get services from container
     const completionService = this.container.resolve<ICompletionService>(SERVICE_IDENTIFIERS.ICompletionService);
     const modelService = this.container.resolve<IModelService>(SERVICE_IDENTIFIERS.IModelService);
@@ -33,11 +33,9 @@ export class CompletionProviderFactory {
   }
   
   /**
-   * Register the completion provider
+   * Register the completion provider, creating one unless given
    */
-  register(context: vscode.ExtensionContext): vscode.Disposable {
-    const provider = this.create();
-    
+  register(context: vscode.ExtensionContext, provider: DIInlineCompletionProvider = this.create()): vscode.Disposable {
     const registration = vscode.languages.registerInlineCompletionItemProvider(
       { pattern: "**" },
       provider
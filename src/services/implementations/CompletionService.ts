@@ -3,22 +3,146 @@
  */
 
 import * as vscode from 'vscode';
+import * as crypto from 'crypto';
+import * as fs from 'fs';
+import * as path from 'path';
 import { Disposable } from '../../utils/Disposable';
 import {
   ICompletionService,
   CompletionContext,
   CompletionOptions,
   CompletionResult,
-  CompletionStats
+  CompletionStats,
+  TokenUsage,
+  FinishReason,
+  PromptInspection,
+  CompletionEndpoint,
+  CompletionType
 } from '../interfaces/ICompletionService';
-import { IOllamaApiService } from '../interfaces/IOllamaApiService';
+import { IOllamaApiService, GenerationStats, ModelOptions } from '../interfaces/IOllamaApiService';
 import { IModelService } from '../interfaces/IModelService';
-import { ICacheService } from '../interfaces/ICacheService';
 import { IConfigurationService } from '../interfaces/IConfigurationService';
 import { SERVICE_IDENTIFIERS } from '../../di';
 import { Singleton, Inject } from '../../di/decorators';
-import { generatePromptFromContext } from '../../inlineCompletionProvider/promptGenerators';
-import { cleanCompletion } from '../../inlineCompletionProvider/responseCleaners';
+import { CancellationManager } from '../../utils/CancellationManager';
+import { Logger } from '../../utils/logger';
+import { OptimizedLRUCache } from '../../utils/OptimizedLRUCache';
+import { ExponentialBackoff } from '../../utils/ErrorRecovery';
+import { RequestQueue } from '../../utils/RequestQueue';
+import { CompletionMetrics } from '../../utils/CompletionMetrics';
+import { DiskCache } from '../../utils/DiskCache';
+import { OllamaApiError } from '../../utils/errors';
+import { resolveWorkspacePath } from '../../utils/pathSecurity';
+import { CompletionProfileMap, resolveCompletionProfile, sanitizeProfile } from '../../config/completionProfiles';
+import { GenerationOverrides } from '../../config/generationOverrides';
+import { ProjectConfigLoader } from '../../config/projectConfig';
+import {
+  generatePromptFromContext,
+  generateFimPrompt,
+  generateFileHeader,
+  getFimTemplate,
+  FimTemplate,
+  INSTRUCTION_CONTEXT_TOKENS,
+  CHAT_SYSTEM_PROMPT,
+  chatPromptTemplate
+} from '../../inlineCompletionProvider/promptGenerators';
+import { buildCrossFileContext } from '../../inlineCompletionProvider/crossFileContext';
+import { CursorSyntax, inferCompletionType, scanCursorSyntax } from '../../inlineCompletionProvider/contextDetectors';
+import { parsePromptTemplate, PromptTemplate } from '../../inlineCompletionProvider/promptTemplate';
+import {
+  estimateTokens,
+  fitToTokenBudget,
+  getPromptBudget,
+  sizeContextWindow,
+  LineWindow
+} from '../../inlineCompletionProvider/tokenBudget';
+import {
+  cleanCompletion,
+  dropDuplicateClosers,
+  dropPartialLine,
+  reindentCompletion,
+  truncateAtSuffix,
+  confineToSyntax,
+  trimTrailingWhitespace,
+  stripLeadingFence,
+  stripMarkdownFences,
+  stripPromptEcho
+} from '../../inlineCompletionProvider/responseCleaners';
+import {
+  getDefaultStopSequences,
+  findStopSequence,
+  truncateAtStopSequence
+} from '../../inlineCompletionProvider/stopSequences';
+
+/**
+ * Drops duplicate candidates and orders the rest by confidence. Ties keep
+ * their sampling order, so the same samples always rank the same way.
+ */
+function rankCandidates(results: CompletionResult[]): CompletionResult[] {
+  const seen = new Set<string>();
+  return results
+    .map((result, index) => ({ result, index }))
+    .filter(({ result }) => {
+      const key = result.text.trim();
+      if (seen.has(key)) {
+        return false;
+      }
+      seen.add(key);
+      return true;
+    })
+    .sort((a, b) => (b.result.confidence ?? 0) - (a.result.confidence ?? 0) || a.index - b.index)
+    .map(({ result }) => result);
+}
+
+/**
+ * Model name reported by echo mode when no model is selected
+ */
+const ECHO_MODEL = 'echo';
+
+/**
+ * How the prompt is rendered: a FIM template, a user template, or the
+ * built-in instruction prompt when neither is set. Chat prompts become the
+ * user message and default to the chat template.
+ */
+interface PromptFormat {
+  fim?: FimTemplate;
+  template?: PromptTemplate;
+  chat?: boolean;
+}
+
+/**
+ * Per-request fields logged as one structured event when a completion ends
+ */
+interface CompletionTrace {
+  requestId: string;
+  model?: string;
+  profile?: string;
+  fallbackFrom?: string[];
+  completionType?: CompletionType;
+  promptTokens?: number;
+  completionTokens?: number;
+  timeToFirstTokenMs?: number;
+  finishReason: string;
+  cached?: boolean;
+  upstreamError?: boolean;
+  error?: string;
+}
+
+/**
+ * What is sent to Ollama for one completion, and the key it is cached under
+ */
+interface CompletionRequest {
+  opts: CompletionOptions;
+  model: string;
+  prompt: string;
+  raw: boolean;
+  chat: boolean;
+  fimFamily?: string;
+  stopSequences: string[];
+  modelOptions: ModelOptions;
+  cacheKey: string;
+  syntax?: CursorSyntax;
+}
 
 /**
  * Completion service implementation
@@ -26,12 +150,35 @@ import { cleanCompletion } from '../../inlineCompletionProvider/responseCleaners
 @Singleton(SERVICE_IDENTIFIERS.ICompletionService)
 export class CompletionService extends Disposable implements ICompletionService {
   private enabled: boolean = true;
+  private fimEnabled: boolean = true;
+  private profiles: CompletionProfileMap = {};
+  private crossFileContextTokens = 512;
+  private lineWindow: LineWindow = { linesBefore: 80, linesAfter: 40 };
+  private inlineMaxTokens = 32;
+  private echoMode = false;
+  private trimWhitespace = true;
+  private balanceBrackets = true;
+  private stopAtSuffix = true;
+  private confineToComment = true;
+  private fileHeader = false;
+  private autoContextWindow = false;
+  private singleLineInline = false;
+  private contextWindowStep = 0;
+  private reindent = true;
+  private generationOverrides: GenerationOverrides = {};
+  private readonly promptTemplates = new Map<string, PromptTemplate | null>();
+  private readonly projectConfig = new ProjectConfigLoader();
+  private maxRetries = 2;
+  private retryBaseDelay = 250;
+  private readonly backoff = new ExponentialBackoff();
   private defaultModel: string = '';
   private defaultOptions: CompletionOptions = {
     maxTokens: 150,
     temperature: 0.7,
-    stopSequences: ['\n\n', '\r\n\r\n', '```', '\n```', '```\n'],
-    contextWindow: 2048
+    contextWindow: 2048,
+    stripMarkdown: true,
+    stripEcho: true,
+    endpoint: 'generate'
   };
   
   private stats: CompletionStats = {
@@ -39,20 +186,34 @@ export class CompletionService extends Disposable implements ICompletionService
     cachedCompletions: 0,
     cacheMisses: 0,
     averageLatency: 0,
-    errorCount: 0
+    errorCount: 0,
+    promptTokens: 0,
+    completionTokens: 0
   };
   
   private latencies: number[] = [];
   private readonly maxLatencyHistory = 100;
   private readonly completionEmitter = new vscode.EventEmitter<CompletionResult>();
+  private readonly cancellationManager = new CancellationManager();
+  private readonly requestQueue = new RequestQueue(2, 8);
+  private readonly metrics = new CompletionMetrics();
+  private readonly completionCache: OptimizedLRUCache<string, CompletionResult>;
+  private readonly diskCache = new DiskCache<CompletionResult>();
+  private storageDirectory: string | undefined;
+  private readonly referencedModels = new Map<string, Set<string>>();
+  private readonly lastContextWindows = new Map<string, number>();
+  private readonly inFlight = new Set<Promise<CompletionResult | null>>();
+  private shuttingDown = false;
+  private shutdownGracePeriod = 2000;
+  private requestTimeout = 10000;
+  private cacheSize = 100;
+  private cacheTtl = 5 * 60 * 1000; // 5 minutes
   
   constructor(
     @Inject(SERVICE_IDENTIFIERS.IOllamaApiService) 
     private readonly apiService: IOllamaApiService,
     @Inject(SERVICE_IDENTIFIERS.IModelService) 
     private readonly modelService: IModelService,
-    @Inject(SERVICE_IDENTIFIERS.ICacheService) 
-    private readonly cacheService: ICacheService,
     @Inject(SERVICE_IDENTIFIERS.IConfigurationService) 
     private readonly configService: IConfigurationService
   ) {
@@ -60,16 +221,42 @@ export class CompletionService extends Disposable implements ICompletionService
     
     // Track the event emitter
     this.track(this.completionEmitter);
+    this.track(this.cancellationManager);
+    this.track(this.requestQueue);
+    this.track(this.metrics);
+    this.track(this.projectConfig);
     
     // Initialize from configuration
     this.loadConfiguration();
+    this.referencedModels.set('settings', new Set(this.getPrimaryModels(undefined, this.profiles)));
+    this.referencedModels.set('selection', new Set([this.modelService.getSelectedModel()].filter(Boolean)));
+    
+    // Completion results are cached by prompt hash
+    this.completionCache = new OptimizedLRUCache<string, CompletionResult>({
+      maxSize: Math.max(this.cacheSize, 1),
+      ttl: this.cacheTtl,
+      evictionPolicy: 'lru',
+      enableStatistics: true
+    });
+    this.track(this.completionCache);
     
     // Listen for configuration changes
     this.track(
       this.configService.onDidChangeConfiguration((event) => {
         if (event.affectsConfiguration('completion') || 
+            event.affectsConfiguration('completionCacheSize') ||
+            event.affectsConfiguration('metrics') ||
             event.affectsConfiguration('enableInlineCompletion')) {
           this.loadConfiguration();
+          this.warmUpNewModels('settings', this.getPrimaryModels(undefined, this.profiles));
+          
+          // Generation parameters may have changed, so drop cached results.
+          // Persisted entries stay: their keys include every parameter.
+          this.completionCache.configure({
+            maxSize: Math.max(this.cacheSize, 1),
+            ttl: this.cacheTtl
+          });
+          this.completionCache.clear();
         }
       })
     );
@@ -79,6 +266,21 @@ export class CompletionService extends Disposable implements ICompletionService
       this.modelService.onModelSelectionChange((event) => {
         if (event.source === 'user' || event.source === 'config') {
           this.defaultModel = event.currentModel;
+          this.completionCache.clear();
+        }
+        if (event.source === 'config') {
+          this.warmUpNewModels('selection', [event.currentModel]);
+        }
+      })
+    );
+    
+    // A project file that now names another model gets it loaded too;
+    // an invalid file loads as no config
+    this.track(
+      this.projectConfig.onDidChange(file => {
+        const project = this.projectConfig.getConfig(file);
+        if (project) {
+          this.warmUpNewModels(file, this.getPrimaryModels(project.settings.model, project.profiles));
         }
       })
     );
@@ -90,79 +292,286 @@ export class CompletionService extends Disposable implements ICompletionService
   async getCompletion(
     context: CompletionContext, 
     options?: CompletionOptions
+  ): Promise<CompletionResult | null> {
+    return this.runCompletion(context, options, `completion:${context.document.uri.toString()}`);
+  }
+  
+  /**
+   * Run a completion as the newest request under its supersession key,
+   * cancelling the one it replaces
+   */
+  private async runCompletion(
+    context: CompletionContext,
+    options: CompletionOptions | undefined,
+    requestId: string
   ): Promise<CompletionResult | null> {
     if (!this.enabled) {
       console.log('[CompletionService] Completions are disabled');
       return null;
     }
     
+    if (context.token?.isCancellationRequested || this.shuttingDown) {
+      return null;
+    }
+    
+    // Only the newest request per document may keep generating; a new
+    // keystroke cancels the superseded request and its upstream call
+    if (this.cancellationManager.getToken(requestId)) {
+      Logger.debug('CompletionService', `Cancelling superseded completion for ${context.document.uri.toString()}`);
+      this.cancellationManager.cancel(requestId, 'Superseded by a newer completion request');
+    }
+    const requestToken = this.cancellationManager.createToken(requestId);
+    const editorCancellation = context.token?.onCancellationRequested(() => {
+      if (this.cancellationManager.getToken(requestId) === requestToken) {
+        this.cancellationManager.cancel(requestId, 'Cancelled by the editor');
+      }
+    });
+    
+    const trace: CompletionTrace = { requestId: crypto.randomUUID(), finishReason: 'unknown' };
     const startTime = Date.now();
     
+    const pending = this.executeCompletion({ ...context, token: requestToken }, options, trace);
+    this.inFlight.add(pending);
     try {
-      // Merge options with defaults
-      const opts = { ...this.defaultOptions, ...options };
-      const model = opts.model || this.defaultModel || this.modelService.getSelectedModel();
-      
-      if (!model) {
-        console.error('[CompletionService] No model selected');
-        throw new Error('No model selected for completion');
-      }
-      
-      console.log(`[CompletionService] Using model: ${model}`);
-      
-      // Generate cache key
-      const cacheKey = this.generateCacheKey(context, model, opts);
+      return await pending;
+    } finally {
+      this.inFlight.delete(pending);
+      editorCancellation?.dispose();
+      this.cancellationManager.release(requestId, requestToken);
+      const latencyMs = Date.now() - startTime;
+      Logger.event('CompletionService', 'completion', {
+        ...trace,
+        language: context.language,
+        latencyMs
+      });
+      this.metrics.record({ ...trace, latencyMs });
+    }
+  }
+  
+  /**
+   * Build the prompt and options a completion would use, without calling
+   * the model or the cache
+   */
+  inspectPrompt(context: CompletionContext, options?: CompletionOptions): PromptInspection {
+    const trace: CompletionTrace = { requestId: crypto.randomUUID(), finishReason: 'inspected' };
+    const request = this.prepareRequest(context, options, trace);
+    return {
+      model: request.model,
+      profile: trace.profile,
+      endpoint: request.chat ? 'chat' : 'generate',
+      completionType: trace.completionType,
+      fimFamily: request.fimFamily,
+      raw: request.raw,
+      system: request.chat ? CHAT_SYSTEM_PROMPT : undefined,
+      prompt: request.prompt,
+      promptTokens: trace.promptTokens ?? estimateTokens(request.prompt),
+      options: request.modelOptions,
+      stopSequences: request.stopSequences
+    };
+  }
+  
+  /**
+   * Build the prompt, call the model and post-process a single completion.
+   * When the model fails, the request is retried on the next fallback model
+   * with whatever is left of the request timeout.
+   */
+  private async executeCompletion(
+    context: CompletionContext,
+    options: CompletionOptions | undefined,
+    trace: CompletionTrace,
+    startTime = Date.now(),
+    fallbackModels?: string[]
+  ): Promise<CompletionResult | null> {
+    const deadline = this.requestTimeout > 0 ? startTime + this.requestTimeout : undefined;
+    let fallbacks: string[] = [];
+    
+    try {
+      const { opts, model, prompt, raw, chat, stopSequences, modelOptions, cacheKey, syntax } = this.prepareRequest(context, options, trace);
+      this.lastContextWindows.set(model, modelOptions.num_ctx ?? 0);
+      fallbacks = fallbackModels ?? (opts.fallbackModels || []).filter(fallback => fallback !== model);
       
       // Check cache
-      const cached = this.cacheService.get<CompletionResult>(cacheKey);
+      const cached = (this.cacheSize > 0 ? this.completionCache.get(cacheKey) : undefined)
+        ?? await this.getPersistedCompletion(cacheKey);
       if (cached) {
         this.stats.cachedCompletions++;
         this.stats.totalCompletions++;
-        cached.cached = true;
-        return cached;
+        trace.finishReason = 'cached';
+        trace.cached = true;
+        return { ...cached, cached: true, usage: undefined };
       } else {
         this.stats.cacheMisses++;
+        trace.cached = false;
       }
       
-      // Extract current line from context
-      const currentLine = context.document.lineAt(context.position.line).text;
-      
-      // Generate prompt
-      const prompt = generatePromptFromContext({
-        prefix: context.prefix,
-        suffix: context.suffix,
-        currentLine: currentLine,
-        language: context.language
-      });
+      if (context.token?.isCancellationRequested) {
+        trace.finishReason = 'cancelled';
+        return null;
+      }
       
-      // Get completion from API
+      // Stream the completion from the API so a cancelled request
+      // aborts the generation instead of waiting for it to finish.
+      // Transient failures are retried with backoff until the token is cancelled,
+      // and each attempt waits for a slot so concurrent editors share the GPU.
       console.log('[CompletionService] Calling API with prompt length:', prompt.length);
-      const response = await this.apiService.generate({
-        model,
-        prompt,
-        options: {
-          temperature: opts.temperature,
-          num_predict: opts.maxTokens,
-          stop: opts.stopSequences
+      let firstTokenLatency: number | undefined;
+      let streamed = '';
+      let generationStats: GenerationStats | undefined;
+      const onChunk = (chunk: string): boolean => {
+        if (firstTokenLatency === undefined) {
+          firstTokenLatency = Date.now() - startTime;
         }
-      });
-      console.log('[CompletionService] Got response length:', response.length);
+        // End the stream at the stop boundary even if Ollama keeps going
+        streamed += chunk;
+        const visible = opts.stripMarkdown ? stripLeadingFence(streamed) : streamed;
+        return findStopSequence(visible, stopSequences, opts.singleLine) === -1;
+      };
+      const onComplete = (stats: GenerationStats): void => { generationStats = stats; };
+    
+      // The request timeout aborts only the generation, so the tokens
+      // streamed before it fired can still be used. With fallbacks left,
+      // a model that has not started streaming by its share of the
+      // remaining time is abandoned early so the next one has time to run.
+      const generation = new vscode.CancellationTokenSource();
+      const linked = context.token?.onCancellationRequested(() => generation.cancel());
+      let timedOut = false;
+      let timer: NodeJS.Timeout | undefined;
+      const expire = (at: number, final: boolean): void => {
+        timer = setTimeout(() => {
+          if (!final && firstTokenLatency !== undefined && deadline !== undefined) {
+            expire(deadline, true);
+            return;
+          }
+          timedOut = true;
+          generation.cancel();
+        }, Math.max(0, at - Date.now()));
+      };
+      if (deadline !== undefined) {
+        const remaining = deadline - Date.now();
+        if (fallbacks.length > 0) {
+          expire(Date.now() + remaining / (fallbacks.length + 1), false);
+        } else {
+          expire(deadline, true);
+        }
+      }
+      let rawResponse: string;
+      try {
+        rawResponse = await this.backoff.retry(() => this.requestQueue.run(async () => {
+          // A retried attempt streams from the start again
+          streamed = '';
+          if (this.echoMode) {
+            return this.echoStream(context.prefix, prompt, onChunk, onComplete, generation.token);
+          }
+          if (chat) {
+            const reply = await this.apiService.chatStream(
+              model,
+              [
+                { role: 'system', content: CHAT_SYSTEM_PROMPT },
+                { role: 'user', content: prompt }
+              ],
+              onChunk,
+              modelOptions,
+              generation.token,
+              onComplete
+            );
+            return reply.message.content;
+          }
+          return this.apiService.generateStream(
+            {
+              model,
+              prompt,
+              // FIM tokens and user templates must reach the model verbatim,
+              // without the model's own chat template
+              raw: raw || undefined,
+              options: modelOptions
+            },
+            onChunk,
+            generation.token,
+            onComplete
+          );
+        }, context.token), {
+          maxAttempts: this.maxRetries + 1,
+          initialDelay: this.retryBaseDelay,
+          maxDelay: 5000,
+          token: context.token,
+          deadline,
+          shouldRetry: (error) => error instanceof OllamaApiError && error.transient,
+          onRetry: (attempt, error) => {
+            Logger.warn('CompletionService', `[${trace.requestId}] Retrying completion (attempt ${attempt + 1}/${this.maxRetries + 1}): ${error.message}`);
+          }
+        });
+      } catch (error) {
+        if (!timedOut || context.token?.isCancellationRequested) {
+          throw error;
+        }
+        rawResponse = streamed;
+      } finally {
+        if (timer) {
+          clearTimeout(timer);
+        }
+        linked?.dispose();
+        generation.dispose();
+      }
+      // A generation cut short by the timeout keeps only its complete lines
+      const partial = timedOut && !generationStats;
+      if (partial) {
+        if (!rawResponse.trim() && fallbacks.length > 0) {
+          throw new Error(`${model} produced no output in time`);
+        }
+        rawResponse = dropPartialLine(rawResponse);
+        Logger.info('CompletionService', `[${trace.requestId}] Timed out after ${Date.now() - startTime}ms, using ${rawResponse.length} streamed characters`);
+      }
+      const unfenced = opts.stripMarkdown ? stripMarkdownFences(rawResponse) : rawResponse;
+      const { text: truncated, stopped } = truncateAtStopSequence(unfenced, stopSequences, opts.singleLine);
+      if (stopped) {
+        console.log('[CompletionService] Truncated response at stop sequence');
+      }
+      trace.timeToFirstTokenMs = firstTokenLatency;
+      trace.promptTokens = generationStats?.promptEvalCount ?? trace.promptTokens;
+      trace.completionTokens = generationStats?.evalCount;
+      const finishReason = this.getFinishReason(generationStats, stopped, partial, opts.maxTokens);
+      trace.finishReason = finishReason;
+      console.log(`[CompletionService] Got response length: ${truncated.length} (first token after ${firstTokenLatency ?? '-'}ms)`);
       
-      // Clean and process response
-      const cleaned = cleanCompletion(response);
+      if (context.token?.isCancellationRequested) {
+        trace.finishReason = 'cancelled';
+        return null;
+      }
+      
+      // Drop a repeat of the code before the cursor, then clean the response
+      // Echo mode repeats the prefix on purpose
+      const response = opts.stripEcho && !this.echoMode ? stripPromptEcho(truncated, context.prefix) : truncated;
+      let cleaned = cleanCompletion(response, opts.stripMarkdown);
+      if (this.trimWhitespace) {
+        cleaned = trimTrailingWhitespace(cleaned);
+      }
+      if (syntax) {
+        cleaned = confineToSyntax(cleaned, syntax);
+      }
+      if (this.reindent) {
+        const lineIndentation = context.document.lineAt(context.position.line).text.match(/^[ \t]*/)?.[0] ?? '';
+        cleaned = reindentCompletion(cleaned, context.indentation, context.tabSize, lineIndentation);
+      }
+      if (this.stopAtSuffix) {
+        cleaned = truncateAtSuffix(cleaned, context.suffix);
+      }
+      if (this.balanceBrackets) {
+        cleaned = dropDuplicateClosers(cleaned, context.suffix, context.prefix);
+      }
       
       // Check if response contained markdown
-      if (response.includes('```')) {
-        console.warn('[CompletionService] Response contained markdown code fences, cleaned from:', response.substring(0, 100));
+      if (rawResponse.includes('```')) {
+        console.warn('[CompletionService] Response contained markdown code fences, cleaned from:', rawResponse.substring(0, 100));
       }
       
       if (!cleaned) {
         console.log('[CompletionService] No valid completion after cleaning');
+        trace.finishReason = 'empty';
         return null;
       }
       
       // Final validation - ensure no markdown remains
-      if (cleaned.includes('```')) {
+      if (opts.stripMarkdown && cleaned.includes('```')) {
         console.error('[CompletionService] Cleaned response still contains markdown:', cleaned);
         // Try to extract just the first line of actual code
         const lines = cleaned.split('\n').filter(line => line.trim() && !line.includes('```'));
@@ -176,18 +585,28 @@ export class CompletionService extends Disposable implements ICompletionService
         return null;
       }
       
-      // Create result
+      // Create result. A generation cut off by the token limit is less
+      // likely to be a complete thought than one that reached a stop.
       const result: CompletionResult = {
         text: cleaned,
-        confidence: 0.8, // TODO: Calculate actual confidence
-        cached: false
+        confidence: finishReason === 'timeout' ? 0.5 : finishReason === 'length' ? 0.6 : 0.8,
+        cached: false,
+        usage: this.getUsage(generationStats),
+        finishReason
       };
       
-      // Cache the result
-      this.cacheService.set(cacheKey, result, 5 * 60 * 1000); // 5 minutes
+      // Cache the result, unless it was cut short by the timeout
+      if (finishReason !== 'timeout') {
+        if (this.cacheSize > 0) {
+          this.completionCache.set(cacheKey, result);
+        }
+        void this.diskCache.set(cacheKey, result);
+      }
       
       // Update stats
       this.stats.totalCompletions++;
+      this.stats.promptTokens += result.usage?.promptTokens ?? 0;
+      this.stats.completionTokens += result.usage?.completionTokens ?? 0;
       this.recordLatency(Date.now() - startTime);
       
       // Emit completion event
@@ -196,12 +615,316 @@ export class CompletionService extends Disposable implements ICompletionService
       return result;
       
     } catch (error) {
+      if (context.token?.isCancellationRequested) {
+        console.log('[CompletionService] Completion cancelled');
+        trace.finishReason = 'cancelled';
+        return null;
+      }
+      if (fallbacks.length > 0 && (deadline === undefined || Date.now() < deadline)) {
+        const message = error instanceof Error ? error.message : String(error);
+        Logger.warn('CompletionService', `[${trace.requestId}] ${trace.model} failed (${message}), falling back to ${fallbacks[0]}`);
+        trace.fallbackFrom = [...(trace.fallbackFrom || []), trace.model || 'none'];
+        return this.executeCompletion(context, { ...options, model: fallbacks[0] }, trace, startTime, fallbacks.slice(1));
+      }
+      trace.finishReason = 'error';
+      trace.error = error instanceof Error ? error.message : String(error);
+      trace.upstreamError = error instanceof OllamaApiError;
       this.stats.errorCount++;
       console.error('Completion error:', error);
       return null;
     }
   }
   
+  /**
+   * Merge the options for a document from lowest to highest precedence:
+   * the global settings and profile, the project file and its profile,
+   * then session overrides and the caller's options
+   */
+  private resolveOptions(
+    context: CompletionContext,
+    options: CompletionOptions | undefined
+  ): { opts: CompletionOptions; profile?: string } {
+    const resolved = resolveCompletionProfile(this.profiles, context.language, context.document.fileName);
+    const project = this.projectConfig.getConfig(context.document.fileName);
+    const projectProfile = project
+      ? resolveCompletionProfile(project.profiles, context.language, context.document.fileName)
+      : undefined;
+    return {
+      opts: {
+        ...this.defaultOptions,
+        ...resolved?.profile,
+        ...project?.settings,
+        ...projectProfile?.profile,
+        ...this.generationOverrides,
+        ...options
+      },
+      profile: projectProfile ? `project:${projectProfile.key}` : resolved?.key
+    };
+  }
+  
+  /**
+   * Resolve the options, model and prompt for a completion, trimming the
+   * code around the cursor to fit the context window
+   */
+  private prepareRequest(
+    context: CompletionContext,
+    options: CompletionOptions | undefined,
+    trace: CompletionTrace
+  ): CompletionRequest {
+    const { opts, profile } = this.resolveOptions(context, options);
+    const model = opts.model || this.defaultModel || this.modelService.getSelectedModel() ||
+      (this.echoMode ? ECHO_MODEL : undefined);
+    
+    if (!model) {
+      console.error('[CompletionService] No model selected');
+      throw new Error('No model selected for completion');
+    }
+    
+    trace.model = model;
+    trace.profile = profile;
+    console.log(`[CompletionService] Using model: ${model}${trace.profile ? ` (profile: ${trace.profile})` : ''}`);
+    
+    // Extract current line from context
+    const currentLine = context.document.lineAt(context.position.line).text;
+    
+    // Inline suggestions are capped well below block completions so
+    // single-line ghost text does not wait on a paragraph of output
+    const completionType = context.completionType ?? inferCompletionType(currentLine, context.position.character);
+    if (completionType === 'inline') {
+      opts.maxTokens = Math.min(opts.maxTokens ?? this.inlineMaxTokens, this.inlineMaxTokens);
+    }
+    // A completion inside a comment or string stays in it, and in a
+    // one-line string it can stop streaming at the end of the line
+    const syntax = this.confineToComment ? scanCursorSyntax(context.prefix, context.language) : undefined;
+    if (syntax?.kind === 'string' && !syntax.multiline) {
+      opts.singleLine = true;
+    }
+    opts.singleLine ??= completionType === 'inline' && this.singleLineInline;
+    trace.completionType = completionType;
+    
+    // A user prompt template takes precedence; otherwise use fill-in-the-middle
+    // when the model family supports it, falling back to the instruction prompt.
+    // FIM tokens only work on /api/generate, so chat never uses them.
+    const chat = opts.endpoint === 'chat';
+    const promptTemplate = opts.promptTemplateFile
+      ? this.getPromptTemplate(opts.promptTemplateFile.trim())
+      : undefined;
+    const fimTemplate = !promptTemplate && !chat && this.fimEnabled ? getFimTemplate(model) : undefined;
+    const format: PromptFormat = { fim: fimTemplate, template: promptTemplate, chat };
+    let stopSequences = opts.stopSequences ?? getDefaultStopSequences(context.language);
+    
+    if (fimTemplate) {
+      console.log(`[CompletionService] Using FIM template: ${fimTemplate.family}`);
+      stopSequences = [...fimTemplate.stop, ...stopSequences];
+    }
+    
+    // Fit the code around the cursor into what the context window has left
+    // once the generation and the prompt template are reserved
+    const contextLength = this.getContextLength(model, opts.contextWindow);
+    const fileHeader = this.fileHeader
+      ? generateFileHeader(path.basename(context.document.fileName), context.language)
+      : '';
+    const overhead = estimateTokens(
+      this.buildPrompt({ ...context, prefix: fileHeader, suffix: '' }, currentLine, format)
+    ) + (chat ? estimateTokens(CHAT_SYSTEM_PROMPT) : 0);
+    const promptBudget = getPromptBudget(contextLength, opts.maxTokens ?? 0, overhead);
+    
+    // Related files get their own slice so a large current file cannot
+    // starve them, capped at a quarter of the prompt budget
+    const relatedBudget = context.relatedFiles?.length
+      ? Math.min(this.crossFileContextTokens, Math.floor(promptBudget / 4))
+      : 0;
+    const cursorBudget = fimTemplate || promptTemplate
+      ? promptBudget - relatedBudget
+      : Math.min(promptBudget - relatedBudget, INSTRUCTION_CONTEXT_TOKENS);
+    
+    const fitted = fitToTokenBudget(context.prefix, context.suffix, cursorBudget, context.language, this.lineWindow);
+    if (fitted.trimmed) {
+      Logger.debug('CompletionService', `Trimmed prompt context to ${this.lineWindow.linesBefore}/${this.lineWindow.linesAfter} lines and ${cursorBudget} tokens (num_ctx ${contextLength})`);
+    }
+    const promptContext = { ...context, prefix: fileHeader + fitted.prefix, suffix: fitted.suffix };
+    let prompt = this.buildPrompt(promptContext, currentLine, format);
+    
+    if (relatedBudget > 0) {
+      const relatedContext = buildCrossFileContext(
+        context.document.fileName,
+        context.prefix + context.suffix,
+        context.language,
+        context.relatedFiles || [],
+        Math.min(relatedBudget, promptBudget - estimateTokens(prompt) + overhead)
+      );
+      if (relatedContext) {
+        prompt = this.buildPrompt(promptContext, currentLine, format, relatedContext);
+      }
+    }
+    
+    // A model that fences its output would hit a ``` stop before writing
+    // any code, so when fences are stripped they are handled client-side.
+    // Stops that start with whitespace, such as "\n\n" or "\ndef ", are
+    // too, since Ollama would match one at the start of a completion that
+    // opens with a line break and return nothing; findStopSequence skips
+    // that leading whitespace.
+    const serverStopSequences = stopSequences.filter(stop =>
+      stop !== '' && !/^\s/.test(stop) && !(opts.stripMarkdown && stop.includes('```'))
+    );
+    
+    trace.promptTokens = estimateTokens(prompt);
+    const numCtx = this.autoContextWindow
+      ? sizeContextWindow(trace.promptTokens, opts.maxTokens ?? 0, contextLength, this.contextWindowStep)
+      : contextLength;
+    if (this.autoContextWindow) {
+      Logger.debug('CompletionService', `[${trace.requestId}] num_ctx ${numCtx} for ${trace.promptTokens} prompt tokens (max ${contextLength})`);
+    }
+    const modelOptions = {
+      temperature: opts.temperature,
+      top_p: opts.topP,
+      seed: opts.seed,
+      num_predict: opts.maxTokens,
+      num_ctx: numCtx,
+      stop: serverStopSequences
+    };
+    
+    return {
+      opts,
+      model,
+      prompt,
+      raw: Boolean(fimTemplate || promptTemplate),
+      chat,
+      fimFamily: fimTemplate?.family,
+      stopSequences,
+      modelOptions,
+      cacheKey: this.generateCacheKey(prompt, model, { ...opts, stopSequences }, context, syntax),
+      syntax
+    };
+  }
+  
+  /**
+   * Models a configuration completes with: its default and the model of
+   * each profile. Fallbacks are left out, since loading one could evict the
+   * model it stands in for.
+   */
+  private getPrimaryModels(defaultModel: string | undefined, profiles: CompletionProfileMap): string[] {
+    return [defaultModel, ...Object.values(profiles || {}).map(profile => sanitizeProfile(profile)?.model)]
+      .filter((model): model is string => !!model);
+  }
+  
+  /**
+   * Load models a source of configuration (the settings, the selected
+   * model or a project file) refers to that it did not before, one at a
+   * time in the background, so the first completion after switching models
+   * does not wait for the load. Failures are logged by the model service only.
+   */
+  private warmUpNewModels(source: string, models: string[]): void {
+    const previous = this.referencedModels.get(source);
+    const added = [...new Set(models)].filter(model => !previous?.has(model));
+    this.referencedModels.set(source, new Set(models));
+    if (added.length === 0 || this.echoMode || !this.configService.get<boolean>('warmUpOnConfigChange', true)) {
+      return;
+    }
+    
+    Logger.info('CompletionService', `Warming up ${added.join(', ')} after a configuration change`);
+    void added.reduce(
+      (chain, model) => chain.then(() => this.warmUpModel(model)),
+      Promise.resolve()
+    );
+  }
+  
+  /**
+   * Load a model with the context window completions will request, so the
+   * first completion does not reload it. Ollama reloads a model whenever
+   * num_ctx changes, so the window last sent for the model is reused; a
+   * model not used yet gets its configured window, which with automatic
+   * sizing is the largest a prompt can ask for.
+   */
+  async warmUpModel(model?: string): Promise<void> {
+    const target = model || this.defaultModel || this.modelService.getSelectedModel();
+    if (!target) {
+      return;
+    }
+    const profile = Object.values(this.profiles || {})
+      .map(value => sanitizeProfile(value))
+      .find(value => value?.model === target);
+    const numCtx = this.lastContextWindows.get(target)
+      || this.getContextLength(target, profile?.contextWindow ?? this.defaultOptions.contextWindow);
+    await this.modelService.warmUpModel(target, { num_ctx: numCtx });
+  }
+  
+  /**
+   * Get the context length to budget against: the configured window, capped
+   * at what the model is known to support
+   */
+  private getContextLength(model: string, contextWindow?: number): number {
+    const configured = contextWindow ?? 2048;
+    const supported = this.modelService.getModelCapabilities(model)?.contextWindow;
+    return supported ? Math.min(configured, supported) : configured;
+  }
+  
+  /**
+   * Render the FIM or instruction prompt for the cursor context
+   */
+  private buildPrompt(
+    context: CompletionContext,
+    currentLine: string,
+    format: PromptFormat,
+    relatedContext?: string
+  ): string {
+    if (format.fim) {
+      return generateFimPrompt({
+        prefix: context.prefix,
+        suffix: context.suffix,
+        relatedContext
+      }, format.fim);
+    }
+    
+    return generatePromptFromContext({
+      prefix: context.prefix,
+      suffix: context.suffix,
+      currentLine: currentLine,
+      language: context.language,
+      fileName: path.basename(context.document.fileName),
+      relatedContext
+    }, format.template ?? (format.chat ? chatPromptTemplate : undefined));
+  }
+  
+  /**
+   * Parse every prompt template file referenced by the settings or a
+   * profile, so template errors surface when configuration loads rather
+   * than on each request
+   */
+  private loadPromptTemplates(): void {
+    this.promptTemplates.clear();
+    const files = new Set(
+      [this.defaultOptions.promptTemplateFile, ...Object.values(this.profiles).map(profile => profile?.promptTemplateFile)]
+        .filter((file): file is string => typeof file === 'string' && file.trim() !== '')
+        .map(file => file.trim())
+    );
+    
+    for (const file of files) {
+      this.getPromptTemplate(file);
+    }
+  }
+  
+  /**
+   * Get a parsed prompt template, loading it on first use. Templates named
+   * only by a project file are loaded this way. A template that fails to
+   * parse is reported once and then treated as unset.
+   */
+  private getPromptTemplate(file: string): PromptTemplate | undefined {
+    if (!this.promptTemplates.has(file)) {
+      const resolved = resolveWorkspacePath(file);
+      try {
+        this.promptTemplates.set(file, parsePromptTemplate(fs.readFileSync(resolved, 'utf8')));
+        Logger.info('CompletionService', `Loaded prompt template: ${resolved}`);
+      } catch (error) {
+        const message = error instanceof Error ? error.message : String(error);
+        Logger.error('CompletionService', `Invalid prompt template ${resolved}`, error);
+        vscode.window.showErrorMessage(`Ollama Copilot: invalid prompt template ${resolved}: ${message}. Using the built-in prompt.`);
+        this.promptTemplates.set(file, null);
+      }
+    }
+    return this.promptTemplates.get(file) ?? undefined;
+  }
+  
   /**
    * Get multiple completions
    */
@@ -210,57 +933,162 @@ export class CompletionService extends Disposable implements ICompletionService
     count: number, 
     options?: CompletionOptions
   ): Promise<CompletionResult[]> {
-    const results: CompletionResult[] = [];
+    const { opts } = this.resolveOptions(context, options);
+    const baseTemperature = opts.temperature ?? 0.7;
+    const baseSeed = opts.seed;
+    const document = context.document.uri.toString();
     
-    // Get multiple completions with different temperatures
-    for (let i = 0; i < count; i++) {
-      const temperature = (options?.temperature || 0.7) + (i * 0.1);
-      const result = await this.getCompletion(context, {
+    // The first candidate is the regular completion; the others sample at
+    // rising temperatures, and with consecutive seeds when a seed is set so
+    // the candidates are reproducible. They run concurrently through the
+    // request queue, each superseding only the same candidate of an older
+    // request for the document.
+    const settled = await Promise.allSettled(Array.from({ length: count }, (_, i) =>
+      this.runCompletion(context, i === 0 ? options : {
         ...options,
-        temperature: Math.min(temperature, 1.5)
-      });
-      
-      if (result) {
-        results.push(result);
-      }
+        temperature: Math.min(baseTemperature + i * 0.1, 1.5),
+        ...(baseSeed !== undefined ? { seed: baseSeed + i } : {})
+      }, i === 0 ? `completion:${document}` : `completion:${document}#${i}`)
+    ));
+    
+    const results = settled
+      .map(outcome => outcome.status === 'fulfilled' ? outcome.value : null)
+      .filter((result): result is CompletionResult => result !== null);
+    const failure = settled.find((outcome): outcome is PromiseRejectedResult => outcome.status === 'rejected');
+    if (results.length === 0 && failure) {
+      throw failure.reason;
     }
     
-    return results;
+    return rankCandidates(results);
   }
   
   /**
    * Cancel ongoing completion requests
    */
   cancelCompletions(): void {
+    this.cancellationManager.cancelAll('Completions cancelled');
     this.apiService.cancelRequests();
   }
   
+  /**
+   * Stop accepting completions and give in-flight ones the grace period
+   * to finish, cancelling whatever is still running after it
+   */
+  async shutdown(): Promise<void> {
+    this.shuttingDown = true;
+    if (this.inFlight.size === 0) {
+      return;
+    }
+    
+    Logger.info('CompletionService', `Waiting up to ${this.shutdownGracePeriod}ms for ${this.inFlight.size} in-flight completion(s)`);
+    let timer: NodeJS.Timeout | undefined;
+    const timedOut = await Promise.race([
+      Promise.allSettled([...this.inFlight]).then(() => false),
+      new Promise<boolean>(resolve => { timer = setTimeout(() => resolve(true), this.shutdownGracePeriod); })
+    ]);
+    clearTimeout(timer);
+    
+    if (timedOut) {
+      Logger.warn('CompletionService', `Cancelling ${this.inFlight.size} completion(s) still running after the grace period`);
+      this.cancelCompletions();
+    }
+  }
+  
   /**
    * Clear completion cache
    */
   clearCache(): void {
-    // Clear all completion cache entries
-    const keys = this.cacheService.keys();
-    keys.forEach(key => {
-      if (key.startsWith('completion:')) {
-        this.cacheService.delete(key);
+    this.completionCache.clear();
+    void this.diskCache.clear();
+  }
+  
+  /**
+   * Set the extension storage directory, the default home of the disk cache
+   */
+  setStorageDirectory(directory: string): void {
+    this.storageDirectory = directory;
+    this.configureDiskCache();
+  }
+  
+  /**
+   * Stand-in for the model in echo mode: streams the last non-empty line
+   * of the prefix back word by word, so the request path can be checked
+   * without Ollama
+   */
+  private async echoStream(
+    prefix: string,
+    prompt: string,
+    onChunk: (chunk: string) => boolean,
+    onComplete: (stats: GenerationStats) => void,
+    token?: vscode.CancellationToken
+  ): Promise<string> {
+    const startTime = Date.now();
+    const lastLine = prefix.split(/\r?\n/).reverse().find(line => line.trim()) ?? '';
+    const chunks = lastLine.trim().match(/\S+\s*/g) ?? [];
+    let response = '';
+    
+    for (const chunk of chunks) {
+      if (token?.isCancellationRequested) {
+        throw new Error('Generation cancelled');
+      }
+      response += chunk;
+      if (!onChunk(chunk)) {
+        return response;
       }
+    }
+    
+    onComplete({
+      doneReason: 'stop',
+      promptEvalCount: estimateTokens(prompt),
+      evalCount: chunks.length,
+      totalDuration: (Date.now() - startTime) * 1e6
     });
+    return response;
+  }
+  
+  /**
+   * Read a completion from the disk cache, promoting it into memory
+   */
+  private async getPersistedCompletion(key: string): Promise<CompletionResult | undefined> {
+    if (!this.diskCache.isEnabled()) {
+      return undefined;
+    }
+    const result = await this.diskCache.get(key);
+    if (result && this.cacheSize > 0) {
+      this.completionCache.set(key, result);
+    }
+    return result;
+  }
+  
+  /**
+   * Point the disk cache at the configured directory, or at the extension
+   * storage directory when none is set
+   */
+  private configureDiskCache(): void {
+    const enabled = this.configService.get<boolean>('completion.diskCache.enabled', false);
+    const configured = this.configService.get<string>('completion.diskCache.directory', '').trim();
+    const directory = configured
+      ? resolveWorkspacePath(configured)
+      : this.storageDirectory && path.join(this.storageDirectory, 'completion-cache');
+    this.diskCache.configure(
+      enabled ? directory : undefined,
+      this.configService.get<number>('completion.diskCache.maxSizeMB', 50) * 1024 * 1024,
+      this.configService.get<number>('completion.diskCache.ttl', 7 * 24 * 60 * 60 * 1000)
+    );
   }
   
   /**
    * Get cache statistics
    */
   getCacheStats(): { size: number; hitRate: number; entries: number } {
-    const cacheStats = this.cacheService.getStats();
-    const completionKeys = this.cacheService.keys().filter(k => k.startsWith('completion:'));
+    const cacheStats = this.completionCache.getStatistics();
     
     return {
       size: cacheStats.memoryUsage,
       hitRate: this.stats.totalCompletions > 0 
         ? this.stats.cachedCompletions / this.stats.totalCompletions 
         : 0,
-      entries: completionKeys.length
+      entries: this.completionCache.size
     };
   }
   
@@ -311,7 +1139,9 @@ export class CompletionService extends Disposable implements ICompletionService
       cachedCompletions: 0,
       cacheMisses: 0,
       averageLatency: 0,
-      errorCount: 0
+      errorCount: 0,
+      promptTokens: 0,
+      completionTokens: 0
     };
     this.latencies = [];
   }
@@ -331,25 +1161,109 @@ export class CompletionService extends Disposable implements ICompletionService
   }
   
   /**
-   * Generate cache key
+   * Get completion metrics in the Prometheus text format
+   */
+  getMetrics(): string | undefined {
+    return this.metrics.render();
+  }
+  
+  /**
+   * Set session generation overrides
+   */
+  setGenerationOverrides(overrides: GenerationOverrides): void {
+    this.generationOverrides = { ...overrides };
+    Logger.info('CompletionService', `Generation overrides: ${JSON.stringify(this.generationOverrides)}`);
+  }
+  
+  /**
+   * Get session generation overrides
+   */
+  getGenerationOverrides(): GenerationOverrides {
+    return { ...this.generationOverrides };
+  }
+  
+  /**
+   * Generate cache key from the rendered prompt, every parameter that
+   * affects generation and the cursor context the cleanup depends on, so
+   * results never leak across models, settings or cursors
    */
   private generateCacheKey(
-    context: CompletionContext, 
+    prompt: string, 
     model: string, 
-    options: CompletionOptions
+    options: CompletionOptions,
+    context: CompletionContext,
+    syntax: CursorSyntax | undefined
   ): string {
-    const key = `completion:${model}:${context.language}:${options.temperature}`;
-    const content = `${context.prefix}<<<CURSOR>>>${context.suffix}`;
-    
-    // Create a simple hash of the content
-    let hash = 0;
-    for (let i = 0; i < content.length; i++) {
-      const char = content.charCodeAt(i);
-      hash = ((hash << 5) - hash) + char;
-      hash = hash & hash; // Convert to 32-bit integer
-    }
+    const normalizedPrompt = prompt.replace(/\r\n/g, '\n');
+    const hash = crypto
+      .createHash('sha256')
+      .update(JSON.stringify({
+        model,
+        echo: this.echoMode,
+        endpoint: options.endpoint,
+        prompt: normalizedPrompt,
+        temperature: options.temperature,
+        topP: options.topP,
+        seed: options.seed,
+        maxTokens: options.maxTokens,
+        stop: options.stopSequences,
+        stripMarkdown: options.stripMarkdown,
+        stripEcho: options.stripEcho,
+        singleLine: options.singleLine,
+        trimWhitespace: this.trimWhitespace,
+        balanceBrackets: this.balanceBrackets,
+        stopAtSuffix: this.stopAtSuffix,
+        confineToComment: this.confineToComment,
+        reindent: this.reindent,
+        // The cached text is already cleaned, and the cleanup reads the
+        // whole prefix and suffix, which a truncated prompt may not contain
+        prefix: context.prefix,
+        suffix: context.suffix,
+        indentation: context.indentation,
+        tabSize: context.tabSize,
+        syntax
+      }))
+      .digest('hex');
     
-    return `${key}:${hash}`;
+    return `completion:${model}:${hash}`;
+  }
+  
+  /**
+   * Why a generation ended, from Ollama's done_reason and our own
+   * truncation. Text cut at a stop sequence here, or a stream we ended at
+   * one (which has no final chunk), stopped; older Ollama versions without
+   * done_reason hit the limit when they generated num_predict tokens.
+   */
+  private getFinishReason(
+    stats: GenerationStats | undefined,
+    stopped: boolean,
+    partial: boolean,
+    maxTokens: number | undefined
+  ): FinishReason {
+    if (stopped || (!stats && !partial)) {
+      return 'stop';
+    }
+    if (!stats) {
+      return 'timeout';
+    }
+    if (stats.doneReason !== undefined) {
+      return stats.doneReason === 'length' ? 'length' : 'stop';
+    }
+    return maxTokens !== undefined && maxTokens > 0 && stats.evalCount !== undefined && stats.evalCount >= maxTokens
+      ? 'length'
+      : 'stop';
+  }
+  
+  /**
+   * Token usage from the final stream chunk, when Ollama reported it
+   */
+  private getUsage(stats: GenerationStats | undefined): TokenUsage | undefined {
+    if (!stats || (stats.promptEvalCount === undefined && stats.evalCount === undefined)) {
+      return undefined;
+    }
+    const promptTokens = stats.promptEvalCount ?? 0;
+    const completionTokens = stats.evalCount ?? 0;
+    return { promptTokens, completionTokens, totalTokens: promptTokens + completionTokens };
   }
   
   /**
@@ -374,13 +1288,61 @@ export class CompletionService extends Disposable implements ICompletionService
   private loadConfiguration(): void {
     this.enabled = this.configService.get<boolean>('enableInlineCompletion', true);
     this.defaultOptions.maxTokens = this.configService.get<number>('completion.maxTokens', 150);
+    this.inlineMaxTokens = Math.max(1, this.configService.get<number>('completion.inlineMaxTokens', 32));
     this.defaultOptions.temperature = this.configService.get<number>('completion.temperature', 0.7);
+    const seed = this.configService.get<number | null>('completion.seed', null);
+    this.defaultOptions.seed = typeof seed === 'number' && Number.isInteger(seed) ? seed : undefined;
     this.defaultOptions.contextWindow = this.configService.get<number>('completion.contextWindow', 2048);
+    this.defaultOptions.stripMarkdown = this.configService.get<boolean>('completion.stripMarkdown', true);
+    this.defaultOptions.stripEcho = this.configService.get<boolean>('completion.stripEcho', true);
+    this.defaultOptions.endpoint = this.configService.get<CompletionEndpoint>('completion.endpoint', 'generate') === 'chat'
+      ? 'chat'
+      : 'generate';
+    this.fimEnabled = this.configService.get<boolean>('completion.enableFim', true);
+    this.echoMode = this.configService.get<boolean>('completion.echoMode', false);
+    this.trimWhitespace = this.configService.get<boolean>('completion.trimTrailingWhitespace', true);
+    this.balanceBrackets = this.configService.get<boolean>('completion.balanceBrackets', true);
+    this.stopAtSuffix = this.configService.get<boolean>('completion.truncateAtSuffix', true);
+    this.confineToComment = this.configService.get<boolean>('completion.confineToComment', true);
+    this.fileHeader = this.configService.get<boolean>('completion.fileHeader', false);
+    this.autoContextWindow = this.configService.get<boolean>('completion.autoContextWindow', false);
+    this.singleLineInline = this.configService.get<boolean>('completion.singleLineInline', false);
+    this.contextWindowStep = Math.max(0, this.configService.get<number>('completion.contextWindowStep', 0));
+    this.reindent = this.configService.get<boolean>('completion.reindent', true);
+    this.shutdownGracePeriod = Math.max(0, this.configService.get<number>('completion.shutdownGracePeriod', 2000));
+    this.requestTimeout = Math.max(0, this.configService.get<number>('completion.timeout', 10000));
+    this.cacheSize = this.configService.get<number>('completionCacheSize', 100);
+    this.cacheTtl = this.configService.get<number>('completion.cacheTTL', 5 * 60 * 1000);
+    this.profiles = this.configService.get<CompletionProfileMap>('completion.profiles', {});
+    this.crossFileContextTokens = this.configService.get<number>('completion.crossFileContextTokens', 512);
+    this.lineWindow = {
+      linesBefore: Math.max(0, this.configService.get<number>('completion.contextLinesBefore', 80)),
+      linesAfter: Math.max(0, this.configService.get<number>('completion.contextLinesAfter', 40))
+    };
+    this.defaultOptions.promptTemplateFile = this.configService.get<string>('completion.promptTemplateFile', '').trim() || undefined;
+    this.loadPromptTemplates();
+    this.maxRetries = Math.max(0, this.configService.get<number>('completion.maxRetries', 2));
+    this.retryBaseDelay = this.configService.get<number>('completion.retryBaseDelay', 250);
+    this.configureDiskCache();
+    this.metrics.configure(
+      this.configService.get<boolean>('metrics.enabled', false),
+      this.configService.get<string>('metrics.textfilePath', '')
+    );
+    this.requestQueue.configure(
+      this.configService.get<number>('completion.maxConcurrentRequests', 2),
+      this.configService.get<number>('completion.maxQueuedRequests', 8)
+    );
     
+    // Without configured stop sequences the per-language defaults apply
     const stopSequences = this.configService.get<string[]>('completion.stopSequences');
-    if (stopSequences) {
-      this.defaultOptions.stopSequences = stopSequences;
-    }
+    this.defaultOptions.stopSequences = Array.isArray(stopSequences) && stopSequences.length > 0
+      ? stopSequences
+      : undefined;
+    
+    const fallbackModels = this.configService.get<string[]>('completion.fallbackModels', []);
+    this.defaultOptions.fallbackModels = Array.isArray(fallbackModels)
+      ? fallbackModels.filter(model => typeof model === 'string' && model.trim()).map(model => model.trim())
+      : [];
   }
   
   /**
@@ -396,7 +1358,9 @@ export class CompletionService extends Disposable implements ICompletionService
       cachedCompletions: 0,
       cacheMisses: 0,
       averageLatency: 0,
-      errorCount: 0
+      errorCount: 0,
+      promptTokens: 0,
+      completionTokens: 0
     };
   }
 }
\ No newline at end of file
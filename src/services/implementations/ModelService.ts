@@ -10,7 +10,7 @@ import {
   ModelSelectionEvent,
   ModelCapabilities
 } from '../interfaces/IModelService';
-import { IOllamaApiService, ModelInfo } from '../interfaces/IOllamaApiService';
+import { IOllamaApiService, ModelInfo, ModelOptions } from '../interfaces/IOllamaApiService';
 import { IConfigurationService } from '../interfaces/IConfigurationService';
 import { ICacheService } from '../interfaces/ICacheService';
 import { SERVICE_IDENTIFIERS } from '../../di';
@@ -292,11 +292,88 @@ export class ModelService extends Disposable implements IModelService {
   }
   
   /**
-   * Download a model
+   * Download a model, logging progress to the output channel
    */
-  async downloadModel(modelName: string): Promise<void> {
-    // This would typically use ollama.pull() but it's not in the current API
-    throw new Error(`Model downloading not implemented ${modelName}`);
+  async downloadModel(modelName: string, token?: vscode.CancellationToken): Promise<void> {
+    let lastLogged = '';
+    await this.apiService.pullModel(
+      modelName,
+      (progress) => {
+        // Log each status once and download progress in 10% steps
+        const percent = progress.total && progress.completed !== undefined
+          ? Math.floor((progress.completed / progress.total) * 10) * 10
+          : undefined;
+        const line = percent !== undefined ? `${progress.status} ${percent}%` : progress.status;
+        if (line !== lastLogged) {
+          Logger.info('ModelService', `Pulling ${modelName}: ${line}`);
+          lastLogged = line;
+        }
+      },
+      token
+    );
+    
+    Logger.info('ModelService', `Pulled model: ${modelName}`);
+    await this.refreshModels();
+  }
+  
+  /**
+   * Pull any of the given models that are not installed. Resolves when all
+   * pulls finish and fails if one errors or the timeout elapses.
+   */
+  async ensureModelsAvailable(
+    models: string[],
+    timeoutMs: number,
+    progress?: vscode.Progress<{ message?: string }>
+  ): Promise<void> {
+    const missing: string[] = [];
+    for (const model of new Set(models)) {
+      if (!await this.hasModel(model) && !await this.hasModel(`${model}:latest`)) {
+        missing.push(model);
+      }
+    }
+    
+    if (missing.length === 0) {
+      return;
+    }
+    
+    Logger.info('ModelService', `Auto-pulling missing models: ${missing.join(', ')}`);
+    const source = new vscode.CancellationTokenSource();
+    const timer = setTimeout(() => source.cancel(), timeoutMs);
+    
+    try {
+      for (const model of missing) {
+        progress?.report({ message: model });
+        await this.downloadModel(model, source.token);
+      }
+    } catch (error) {
+      const reason = source.token.isCancellationRequested
+        ? `timed out after ${Math.round(timeoutMs / 1000)}s`
+        : (error instanceof Error ? error.message : String(error));
+      Logger.error('ModelService', 'Auto-pull failed', error);
+      throw new Error(`Failed to auto-pull models (${missing.join(', ')}): ${reason}`);
+    } finally {
+      clearTimeout(timer);
+      source.dispose();
+    }
+  }
+  
+  /**
+   * Load a model ahead of the first request. Failures are logged only,
+   * since the first real request loads the model anyway.
+   */
+  async warmUpModel(modelName?: string, options?: ModelOptions): Promise<void> {
+    const model = modelName || this.getSelectedModel();
+    if (!model) {
+      return;
+    }
+    
+    const startTime = Date.now();
+    try {
+      await this.apiService.loadModel(model, options);
+      Logger.info('ModelService', `Warmed up ${model} in ${Date.now() - startTime}ms`);
+    } catch (error) {
+      Logger.warn('ModelService', `Warm-up of ${model} failed: ${error instanceof Error ? error.message : String(error)}`);
+    }
   }
   
   /**
@@ -2,16 +2,22 @@
  * Ollama API service implementation
  */
 
+import * as vscode from "vscode";
 import { Ollama } from "ollama";
 import { Disposable } from "../../utils/Disposable";
 import { Logger } from "../../utils/logger";
+import { OllamaApiError, isTransientError } from "../../utils/errors";
+import { HttpClient } from "../../utils/httpClient";
 import {
   IOllamaApiService,
   ChatMessage,
   ChatResponse,
   GenerateOptions,
+  GenerationStats,
+  HealthStatus,
   ModelInfo,
   ModelOptions,
+  PullProgress,
   StreamCallback,
 } from "../interfaces/IOllamaApiService";
 import { IConfigurationService } from "../interfaces/IConfigurationService";
@@ -25,6 +31,7 @@ import { Singleton, Inject } from "../../di/decorators";
 export class OllamaApiService extends Disposable implements IOllamaApiService {
   private _apiHost: string;
   private ollamaClient: Ollama;
+  private httpClient: HttpClient | undefined;
   private static instanceCounter = 0;
   private instanceId: number;
 
@@ -43,7 +50,7 @@ export class OllamaApiService extends Disposable implements IOllamaApiService {
       "http://localhost:11434"
     );
     // Create Ollama client with the configured host
-    this.ollamaClient = new Ollama({ host: this._apiHost });
+    this.ollamaClient = this.createClient();
     // Listen for configuration changes
     this.track(
       this.configService.onDidChangeConfiguration((event) => {
@@ -51,7 +58,8 @@ export class OllamaApiService extends Disposable implements IOllamaApiService {
           `[OllamaApiService.ts] [${this.instanceId}] onDidChangeConfiguration callback fired`
         );
         const affectsOllamaApiHost =
-          event.affectsConfiguration("ollama.apiHost");
+          event.affectsConfiguration("ollama.apiHost") ||
+          event.affectsConfiguration("ollama.http");
         console.log(
           `[OllamaApiService.ts] [${this.instanceId}] affectsConfiguration('ollama.apiHost'):`,
           affectsOllamaApiHost
@@ -67,7 +75,7 @@ export class OllamaApiService extends Disposable implements IOllamaApiService {
               newHost
             );
             this._apiHost = newHost;
-            this.ollamaClient = new Ollama({ host: this._apiHost });
+            this.ollamaClient = this.createClient();
             console.log(
               `[OllamaApiService.ts] [${this.instanceId}] (deferred) ollamaClient updated with host:`,
               this._apiHost
@@ -78,6 +86,53 @@ export class OllamaApiService extends Disposable implements IOllamaApiService {
     );
   }
 
+  /**
+   * Creates a client for the current host with the connection settings,
+   * closing the previous client's pooled connections once its open
+   * requests finish
+   */
+  private createClient(): Ollama {
+    this.httpClient?.dispose();
+    this.httpClient = new HttpClient({
+      verifyTls: this.configService.get<boolean>("http.verifyTls", true),
+      connectTimeout: this.configService.get<number>("http.connectTimeout", 10000),
+      maxIdleConnections: this.configService.get<number>("http.maxIdleConnections", 4),
+    });
+
+    return this.createOllama(this.httpClient.fetch);
+  }
+
+  /**
+   * Creates an Ollama client over the given fetch with the configured headers
+   */
+  private createOllama(fetcher: HttpClient["fetch"]): Ollama {
+    const headers = this.configService.get<Record<string, string>>("http.headers", {});
+    return new Ollama({
+      host: this._apiHost,
+      fetch: fetcher as typeof fetch,
+      headers: headers && typeof headers === "object" ? headers : undefined,
+    });
+  }
+
+  /**
+   * Gets a client whose requests abort as soon as the token is cancelled,
+   * including while still waiting for the response headers, which is when
+   * Ollama loads the model and evaluates the prompt. Dispose the returned
+   * subscription once the request has finished.
+   */
+  private clientFor(token?: vscode.CancellationToken): { client: Ollama; cancellation?: vscode.Disposable } {
+    const httpClient = this.httpClient;
+    if (!token || !httpClient) {
+      return { client: this.ollamaClient };
+    }
+    const controller = new AbortController();
+    if (token.isCancellationRequested) {
+      controller.abort();
+    }
+    const cancellation = token.onCancellationRequested(() => controller.abort());
+    return { client: this.createOllama(httpClient.withSignal(controller.signal)), cancellation };
+  }
+
   /**
    * Get the current API host
    */
@@ -91,7 +146,7 @@ export class OllamaApiService extends Disposable implements IOllamaApiService {
   setApiHost(host: string): void {
     this._apiHost = host;
     // Recreate Ollama client with new host
-    this.ollamaClient = new Ollama({ host: this._apiHost });
+    this.ollamaClient = this.createClient();
 
     console.log("Host updated: ", host);
 
@@ -165,6 +220,50 @@ export class OllamaApiService extends Disposable implements IOllamaApiService {
     }
   }
 
+  /**
+   * Pull a model, streaming download progress
+   */
+  async pullModel(
+    modelName: string,
+    onProgress?: (progress: PullProgress) => void,
+    token?: vscode.CancellationToken
+  ): Promise<void> {
+    Logger.info("OllamaApiService", `Pulling model: ${modelName}`);
+    let cancellation: vscode.Disposable | undefined;
+    try {
+      const response = await this.ollamaClient.pull({
+        model: modelName,
+        stream: true,
+      });
+
+      if (token) {
+        if (token.isCancellationRequested) {
+          response.abort();
+        }
+        cancellation = token.onCancellationRequested(() => response.abort());
+      }
+
+      for await (const progress of response) {
+        onProgress?.({
+          status: progress.status,
+          total: progress.total,
+          completed: progress.completed,
+        });
+      }
+    } catch (error) {
+      if (error instanceof Error && error.name === "AbortError") {
+        throw new Error(`Pull of ${modelName} cancelled`);
+      }
+      throw new Error(
+        `Failed to pull ${modelName}: ${
+          error instanceof Error ? error.message : String(error)
+        }`
+      );
+    } finally {
+      cancellation?.dispose();
+    }
+  }
+
   /**
    * Generate completion
    */
@@ -174,24 +273,15 @@ export class OllamaApiService extends Disposable implements IOllamaApiService {
       this._apiHost
     );
     try {
-      // Add system instruction for code completion if not already present
-      let system = options.system;
-      if (
-        (!system && options.prompt.includes("code completion")) ||
-        options.prompt.includes("Complete the")
-      ) {
-        system =
-          "You are a code completion assistant. Always return ONLY raw code without any markdown formatting, code fences (```), or language identifiers. Never wrap code in markdown blocks.";
-      }
-
       const response = await this.ollamaClient.generate({
         model: options.model,
         prompt: options.prompt,
-        system: system,
+        system: this.resolveSystemPrompt(options),
         template: options.template,
         context: options.context,
         stream: false,
         raw: options.raw,
+        keep_alive: this.getKeepAlive(),
         options: options.options,
       });
 
@@ -219,30 +309,45 @@ export class OllamaApiService extends Disposable implements IOllamaApiService {
    */
   async generateStream(
     options: GenerateOptions,
-    onStream: StreamCallback
+    onStream: StreamCallback,
+    token?: vscode.CancellationToken,
+    onComplete?: (stats: GenerationStats) => void
   ): Promise<string> {
     console.log(
       `[OllamaApiService.ts] [${this.instanceId}] generateStream using host:`,
       this._apiHost
     );
+    const { client, cancellation } = this.clientFor(token);
     try {
       let fullResponse = "";
 
-      const response = await this.ollamaClient.generate({
+      const response = await client.generate({
         model: options.model,
         prompt: options.prompt,
-        system: options.system,
+        system: this.resolveSystemPrompt(options),
         template: options.template,
         context: options.context,
         stream: true,
         raw: options.raw,
+        keep_alive: this.getKeepAlive(),
         options: options.options,
       });
 
       for await (const chunk of response) {
         if (chunk.response) {
           fullResponse += chunk.response;
-          onStream(chunk.response);
+          if (onStream(chunk.response) === false) {
+            response.abort();
+            break;
+          }
+        }
+        if (chunk.done) {
+          onComplete?.({
+            doneReason: chunk.done_reason,
+            promptEvalCount: chunk.prompt_eval_count,
+            evalCount: chunk.eval_count,
+            totalDuration: chunk.total_duration,
+          });
         }
       }
 
@@ -251,17 +356,24 @@ export class OllamaApiService extends Disposable implements IOllamaApiService {
       if (error instanceof Error && error.name === "AbortError") {
         throw new Error("Generation cancelled");
       }
+      const status = (error as { status_code?: number }).status_code;
       if (error instanceof Error && error.message.includes("ECONNREFUSED")) {
-        throw new Error(
+        throw new OllamaApiError(
           "Failed to connect to Ollama. Please ensure the Ollama service is running on " +
-            this._apiHost
+            this._apiHost,
+          status,
+          true
         );
       }
-      throw new Error(
+      throw new OllamaApiError(
         `Failed to generate: ${
           error instanceof Error ? error.message : String(error)
-        }`
+        }`,
+        status,
+        isTransientError(error)
       );
+    } finally {
+      cancellation?.dispose();
     }
   }
 
@@ -287,6 +399,7 @@ export class OllamaApiService extends Disposable implements IOllamaApiService {
         model,
         messages,
         stream: false,
+        keep_alive: this.getKeepAlive(),
         options,
       });
 
@@ -329,20 +442,24 @@ export class OllamaApiService extends Disposable implements IOllamaApiService {
     model: string,
     messages: ChatMessage[],
     onStream: StreamCallback,
-    options?: ModelOptions
+    options?: ModelOptions,
+    token?: vscode.CancellationToken,
+    onComplete?: (stats: GenerationStats) => void
   ): Promise<ChatResponse> {
     console.log(
       `[OllamaApiService.ts] [${this.instanceId}] chatStream using host:`,
       this._apiHost
     );
+    const { client, cancellation } = this.clientFor(token);
     try {
       let lastResponse: any;
       let fullContent = "";
 
-      const response = await this.ollamaClient.chat({
+      const response = await client.chat({
         model,
         messages,
         stream: true,
+        keep_alive: this.getKeepAlive(),
         options,
       });
 
@@ -350,7 +467,18 @@ export class OllamaApiService extends Disposable implements IOllamaApiService {
         lastResponse = chunk;
         if (chunk.message?.content) {
           fullContent += chunk.message.content;
-          onStream(chunk.message.content);
+          if (onStream(chunk.message.content) === false) {
+            response.abort();
+            break;
+          }
+        }
+        if (chunk.done) {
+          onComplete?.({
+            doneReason: chunk.done_reason,
+            promptEvalCount: chunk.prompt_eval_count,
+            evalCount: chunk.eval_count,
+            totalDuration: chunk.total_duration,
+          });
         }
       }
 
@@ -378,17 +506,24 @@ export class OllamaApiService extends Disposable implements IOllamaApiService {
       if (error instanceof Error && error.name === "AbortError") {
         throw new Error("Chat cancelled");
       }
+      const status = (error as { status_code?: number }).status_code;
       if (error instanceof Error && error.message.includes("ECONNREFUSED")) {
-        throw new Error(
+        throw new OllamaApiError(
           "Failed to connect to Ollama. Please ensure the Ollama service is running on " +
-            this._apiHost
+            this._apiHost,
+          status,
+          true
         );
       }
-      throw new Error(
+      throw new OllamaApiError(
         `Failed to chat: ${
           error instanceof Error ? error.message : String(error)
-        }`
+        }`,
+        status,
+        isTransientError(error)
       );
+    } finally {
+      cancellation?.dispose();
     }
   }
 
@@ -422,10 +557,111 @@ export class OllamaApiService extends Disposable implements IOllamaApiService {
     }
   }
 
+  /**
+   * Readiness check that reports the Ollama version and any required
+   * models that are not pulled
+   */
+  async checkHealth(requiredModels: string[]): Promise<HealthStatus> {
+    const checkedAt = new Date().toISOString();
+    try {
+      const [version, models] = await Promise.all([
+        this.ollamaClient.version(),
+        this.listModels(),
+      ]);
+      const installed = new Set(models.map((model) => model.name));
+      // Ollama reports untagged models with an implicit ":latest" tag
+      const missingModels = [...new Set(requiredModels)].filter(
+        (model) => !installed.has(model) && !installed.has(`${model}:latest`)
+      );
+
+      if (missingModels.length > 0) {
+        Logger.warn(
+          "OllamaApiService",
+          `Health check found missing models: ${missingModels.join(", ")}`
+        );
+      }
+
+      return {
+        status: missingModels.length === 0 ? "ok" : "unavailable",
+        host: this._apiHost,
+        ollamaVersion: version.version,
+        missingModels,
+        error:
+          missingModels.length > 0
+            ? `Models not pulled: ${missingModels.join(", ")}`
+            : undefined,
+        checkedAt,
+      };
+    } catch (error) {
+      Logger.error("OllamaApiService", "Readiness check failed", error);
+      return {
+        status: "unavailable",
+        host: this._apiHost,
+        missingModels: [],
+        error: `Cannot reach Ollama at ${this._apiHost}: ${
+          error instanceof Error ? error.message : String(error)
+        }`,
+        checkedAt,
+      };
+    }
+  }
+
+  /**
+   * Load a model into memory without generating, so the next request
+   * does not wait for it to load
+   */
+  async loadModel(modelName: string, options?: ModelOptions): Promise<void> {
+    try {
+      await this.ollamaClient.generate({
+        model: modelName,
+        prompt: "",
+        stream: false,
+        options,
+        keep_alive: this.getKeepAlive(),
+      });
+    } catch (error) {
+      const status = (error as { status_code?: number }).status_code;
+      throw new OllamaApiError(
+        `Failed to load model ${modelName}: ${
+          error instanceof Error ? error.message : String(error)
+        }`,
+        status,
+        isTransientError(error)
+      );
+    }
+  }
+
+  /**
+   * Keep-alive sent with every request: "-1" or a negative number keeps
+   * the model loaded, a bare number is seconds, and a duration like "30m"
+   * is passed as is. Unset leaves Ollama's default.
+   */
+  private getKeepAlive(): string | number | undefined {
+    const value = String(this.configService.get<string | number>("keepAlive", "")).trim();
+    if (!value) {
+      return undefined;
+    }
+    return /^-?\d+$/.test(value) ? Number(value) : value;
+  }
+
+  /**
+   * Add system instruction for code completion if not already present
+   */
+  private resolveSystemPrompt(options: GenerateOptions): string | undefined {
+    if (
+      (!options.system && options.prompt.includes("code completion")) ||
+      options.prompt.includes("Complete the")
+    ) {
+      return "You are a code completion assistant. Always return ONLY raw code without any markdown formatting, code fences (```), or language identifiers. Never wrap code in markdown blocks.";
+    }
+    return options.system;
+  }
+
   /**
    * Cleanup on dispose
    */
   protected onDispose(): void {
-    // Cleanup any resources if needed
+    this.httpClient?.dispose();
+    this.httpClient = undefined;
   }
 }
@@ -3,6 +3,24 @@
  */
 
 import * as vscode from 'vscode';
+import { GenerationOverrides } from '../../config/generationOverrides';
+import { ModelOptions } from './IOllamaApiService';
+
+/**
+ * Another open file offered as cross-file context
+ */
+export interface RelatedFile {
+  fileName: string;
+  languageId: string;
+  content: string;
+  lastEdited?: number;
+}
+
+/**
+ * Kind of completion requested: a short suggestion inside a line, or a
+ * multi-line block
+ */
+export type CompletionType = 'inline' | 'block';
 
 /**
  * Completion context
@@ -14,8 +32,18 @@ export interface CompletionContext {
   suffix: string;
   language: string;
   indentation: string;
+  tabSize?: number;
+  relatedFiles?: RelatedFile[];
+  completionType?: CompletionType;
+  token?: vscode.CancellationToken;
 }
 
+/**
+ * Ollama endpoint completions are sent to: /api/generate, or /api/chat
+ * for instruct models that work best with a system prompt
+ */
+export type CompletionEndpoint = 'generate' | 'chat';
+
 /**
  * Completion options
  */
@@ -23,8 +51,34 @@ export interface CompletionOptions {
   model?: string;
   maxTokens?: number;
   temperature?: number;
+  topP?: number;
+  seed?: number;
+  stopSequences?: string[];
+  contextWindow?: number;
+  stripMarkdown?: boolean;
+  stripEcho?: boolean;
+  promptTemplateFile?: string;
+  endpoint?: CompletionEndpoint;
+  fallbackModels?: string[];
+  singleLine?: boolean;
+}
+
+/**
+ * Completion profile applied to files of a given language or extension
+ */
+export interface CompletionProfile {
+  model?: string;
+  maxTokens?: number;
+  temperature?: number;
+  topP?: number;
+  seed?: number;
   stopSequences?: string[];
   contextWindow?: number;
+  stripMarkdown?: boolean;
+  stripEcho?: boolean;
+  promptTemplateFile?: string;
+  endpoint?: CompletionEndpoint;
+  fallbackModels?: string[];
 }
 
 /**
@@ -35,6 +89,41 @@ export interface CompletionResult {
   range?: vscode.Range;
   confidence?: number;
   cached?: boolean;
+  usage?: TokenUsage;
+  finishReason?: FinishReason;
+}
+
+/**
+ * Why generation ended: a stop sequence or the end of the model's output
+ * ('stop'), the token limit ('length'), or the request timeout ('timeout')
+ */
+export type FinishReason = 'stop' | 'length' | 'timeout';
+
+/**
+ * Tokens a completion cost, from Ollama's prompt_eval_count and eval_count.
+ * Results served from the cache cost nothing and carry no usage.
+ */
+export interface TokenUsage {
+  promptTokens: number;
+  completionTokens: number;
+  totalTokens: number;
+}
+
+/**
+ * The request a completion would send to Ollama, built without sending it
+ */
+export interface PromptInspection {
+  model: string;
+  profile?: string;
+  endpoint: CompletionEndpoint;
+  completionType?: CompletionType;
+  fimFamily?: string;
+  raw: boolean;
+  system?: string;
+  prompt: string;
+  promptTokens: number;
+  options: ModelOptions;
+  stopSequences: string[];
 }
 
 /**
@@ -56,6 +145,8 @@ export interface CompletionStats {
   cacheMisses: number;
   averageLatency: number;
   errorCount: number;
+  promptTokens: number;
+  completionTokens: number;
 }
 
 /**
@@ -72,11 +163,23 @@ export interface ICompletionService extends vscode.Disposable {
    */
   getCompletions(context: CompletionContext, count: number, options?: CompletionOptions): Promise<CompletionResult[]>;
   
+  /**
+   * Build the prompt and options a completion would use, without calling
+   * the model
+   */
+  inspectPrompt(context: CompletionContext, options?: CompletionOptions): PromptInspection;
+  
   /**
    * Cancel ongoing completion requests
    */
   cancelCompletions(): void;
   
+  /**
+   * Stop accepting completions and wait for in-flight ones to finish
+   * within the configured grace period
+   */
+  shutdown(): Promise<void>;
+  
   /**
    * Clear completion cache
    */
@@ -87,6 +190,18 @@ export interface ICompletionService extends vscode.Disposable {
    */
   getCacheStats(): { size: number; hitRate: number; entries: number };
   
+  /**
+   * Set the directory used for persisted completions
+   */
+  setStorageDirectory(directory: string): void;
+  
+  /**
+   * Load a model with the context window completions will request, so the
+   * first completion does not reload it. Uses the default model when none
+   * is given; failures are only logged.
+   */
+  warmUpModel(model?: string): Promise<void>;
+  
   /**
    * Set default model
    */
@@ -126,4 +241,20 @@ export interface ICompletionService extends vscode.Disposable {
    * Configure completion behavior
    */
   configure(options: CompletionOptions): void;
+  
+  /**
+   * Get completion metrics in the Prometheus text format, or undefined when
+   * metrics are disabled
+   */
+  getMetrics(): string | undefined;
+  
+  /**
+   * Override generation parameters for this session, on top of profile defaults
+   */
+  setGenerationOverrides(overrides: GenerationOverrides): void;
+  
+  /**
+   * Get the active session overrides
+   */
+  getGenerationOverrides(): GenerationOverrides;
 }
\ No newline at end of file
@@ -3,7 +3,7 @@
  */
 
 import * as vscode from 'vscode';
-import { ModelInfo } from './IOllamaApiService';
+import { ModelInfo, ModelOptions } from './IOllamaApiService';
 
 /**
  * Model selection event
@@ -92,7 +92,22 @@ export interface IModelService extends vscode.Disposable {
   /**
    * Download a model
    */
-  downloadModel(modelName: string): Promise<void>;
+  downloadModel(modelName: string, token?: vscode.CancellationToken): Promise<void>;
+  
+  /**
+   * Pull any of the given models that are not installed, failing on error or timeout
+   */
+  ensureModelsAvailable(
+    models: string[],
+    timeoutMs: number,
+    progress?: vscode.Progress<{ message?: string }>
+  ): Promise<void>;
+  
+  /**
+   * Load a model into Ollama's memory so the first completion is not slowed
+   * by the load. Uses the selected model when none is given.
+   */
+  warmUpModel(modelName?: string, options?: ModelOptions): Promise<void>;
   
   /**
    * Delete a model
@@ -46,7 +46,9 @@ export interface ModelOptions {
   temperature?: number;
   top_k?: number;
   top_p?: number;
+  seed?: number;
   num_predict?: number;
+  num_ctx?: number;
   stop?: string[];
 }
 
@@ -67,9 +69,40 @@ export interface ModelInfo {
 }
 
 /**
- * Stream callback
+ * Readiness of the Ollama service for the configured models
  */
-export type StreamCallback = (chunk: string) => void;
+export interface HealthStatus {
+  status: 'ok' | 'unavailable';
+  host: string;
+  ollamaVersion?: string;
+  missingModels: string[];
+  error?: string;
+  checkedAt: string;
+}
+
+/**
+ * Metadata from the final chunk of a generation
+ */
+export interface GenerationStats {
+  doneReason?: string;
+  promptEvalCount?: number;
+  evalCount?: number;
+  totalDuration?: number;
+}
+
+/**
+ * Progress reported while pulling a model
+ */
+export interface PullProgress {
+  status: string;
+  total?: number;
+  completed?: number;
+}
+
+/**
+ * Stream callback. Returning false stops the generation early.
+ */
+export type StreamCallback = (chunk: string) => void | boolean;
 
 /**
  * Ollama API service interface
@@ -95,15 +128,36 @@ export interface IOllamaApiService extends vscode.Disposable {
    */
   hasModel(modelName: string): Promise<boolean>;
   
+  /**
+   * Pull a model from the registry, reporting progress as it downloads
+   */
+  pullModel(
+    modelName: string,
+    onProgress?: (progress: PullProgress) => void,
+    token?: vscode.CancellationToken
+  ): Promise<void>;
+  
+  /**
+   * Load a model into memory ahead of the first request. Options such as
+   * num_ctx should match the requests that follow, since Ollama reloads
+   * the model when they differ.
+   */
+  loadModel(modelName: string, options?: ModelOptions): Promise<void>;
+  
   /**
    * Generate completion
    */
   generate(options: GenerateOptions): Promise<string>;
   
   /**
-   * Generate completion with streaming
+   * Generate completion with streaming, aborting upstream when the token is cancelled
    */
-  generateStream(options: GenerateOptions, onStream: StreamCallback): Promise<string>;
+  generateStream(
+    options: GenerateOptions,
+    onStream: StreamCallback,
+    token?: vscode.CancellationToken,
+    onComplete?: (stats: GenerationStats) => void
+  ): Promise<string>;
   
   /**
    * Chat with a model
@@ -111,13 +165,15 @@ export interface IOllamaApiService extends vscode.Disposable {
   chat(model: string, messages: ChatMessage[], options?: ModelOptions): Promise<ChatResponse>;
   
   /**
-   * Chat with streaming
+   * Chat with streaming, aborting upstream when the token is cancelled
    */
   chatStream(
     model: string, 
     messages: ChatMessage[], 
     onStream: StreamCallback,
-    options?: ModelOptions
+    options?: ModelOptions,
+    token?: vscode.CancellationToken,
+    onComplete?: (stats: GenerationStats) => void
   ): Promise<ChatResponse>;
   
   /**
@@ -129,4 +185,9 @@ export interface IOllamaApiService extends vscode.Disposable {
    * Health check
    */
   healthCheck(): Promise<boolean>;
+  
+  /**
+   * Readiness check: verifies connectivity and that each required model is pulled
+   */
+  checkHealth(requiredModels: string[]): Promise<HealthStatus>;
 }
\ No newline at end of file
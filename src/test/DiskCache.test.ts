@@ -0,0 +1,80 @@
+import * as assert from 'assert';
+import * as os from 'os';
+import * as path from 'path';
+import { promises as fs } from 'fs';
+import { DiskCache } from '../utils/DiskCache';
+
+suite('DiskCache Test Suite', () => {
+  let directory: string;
+  let cache: DiskCache<string>;
+
+  setup(async () => {
+    directory = await fs.mkdtemp(path.join(os.tmpdir(), 'ollama-copilot-cache-test-'));
+    cache = new DiskCache<string>();
+    cache.configure(directory, 1024 * 1024, 0);
+  });
+
+  teardown(async () => {
+    await fs.rm(directory, { recursive: true, force: true });
+  });
+
+  test('should be disabled without a directory', async () => {
+    const disabled = new DiskCache<string>();
+    disabled.configure(undefined, 1024, 0);
+    assert.strictEqual(disabled.isEnabled(), false);
+    await disabled.set('key', 'value');
+    assert.strictEqual(await disabled.get('key'), undefined);
+  });
+
+  test('should return stored entries', async () => {
+    await cache.set('key', 'value');
+    assert.strictEqual(await cache.get('key'), 'value');
+    assert.strictEqual(await cache.get('other'), undefined);
+  });
+
+  test('should keep entries across instances', async () => {
+    await cache.set('key', 'value');
+    const reopened = new DiskCache<string>();
+    reopened.configure(directory, 1024 * 1024, 0);
+    assert.strictEqual(await reopened.get('key'), 'value');
+  });
+
+  test('should expire entries after the ttl', async () => {
+    cache.configure(directory, 1024 * 1024, 1);
+    await cache.set('key', 'value');
+    await new Promise(resolve => setTimeout(resolve, 10));
+    assert.strictEqual(await cache.get('key'), undefined);
+  });
+
+  test('should evict the least recently used entries beyond the size limit', async () => {
+    cache.configure(directory, 200, 0);
+    await cache.set('first', 'a'.repeat(50));
+    await cache.set('second', 'b'.repeat(50));
+    await cache.set('third', 'c'.repeat(50));
+    assert.strictEqual(await cache.get('first'), undefined);
+    assert.strictEqual(await cache.get('third'), 'c'.repeat(50));
+  });
+
+  test('should treat a corrupt entry as a miss', async () => {
+    await cache.set('key', 'value');
+    const entries = path.join(directory, 'ollama-copilot-cache');
+    const [name] = await fs.readdir(entries);
+    await fs.writeFile(path.join(entries, name), '{ not json', 'utf8');
+    assert.strictEqual(await cache.get('key'), undefined);
+    assert.deepStrictEqual(await fs.readdir(entries), []);
+  });
+
+  test('should only delete its own files when cleared', async () => {
+    await fs.writeFile(path.join(directory, 'package.json'), '{}', 'utf8');
+    const entries = path.join(directory, 'ollama-copilot-cache');
+    await fs.mkdir(entries, { recursive: true });
+    await fs.writeFile(path.join(entries, 'notes.json'), '{}', 'utf8');
+
+    await cache.set('key', 'value');
+    await cache.clear();
+
+    assert.strictEqual(await cache.get('key'), undefined);
+    assert.deepStrictEqual((await fs.readdir(directory)).sort(), ['ollama-copilot-cache', 'package.json']);
+    assert.deepStrictEqual(await fs.readdir(entries), ['notes.json']);
+  });
+});
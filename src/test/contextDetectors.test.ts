@@ -0,0 +1,39 @@
+import * as assert from 'assert';
+import { scanCursorSyntax } from '../inlineCompletionProvider/contextDetectors';
+
+suite('contextDetectors Test Suite', () => {
+  suite('scanCursorSyntax', () => {
+    test('should find a line comment at the cursor', () => {
+      assert.deepStrictEqual(scanCursorSyntax('const a = 1;\n// explain ', 'typescript'), {
+        kind: 'comment',
+        multiline: false,
+        marker: '//'
+      });
+    });
+
+    test('should find an unclosed block comment', () => {
+      assert.deepStrictEqual(scanCursorSyntax('/**\n * Adds ', 'javascript'), {
+        kind: 'comment',
+        multiline: true,
+        close: '*/'
+      });
+    });
+
+    test('should find an unclosed string', () => {
+      assert.deepStrictEqual(scanCursorSyntax('x = """docs\n', 'python'), {
+        kind: 'string',
+        multiline: true,
+        close: '"""'
+      });
+    });
+
+    test('should treat closed comments and strings as code', () => {
+      assert.strictEqual(scanCursorSyntax('/* a */ const s = "//";\nfoo(', 'typescript').kind, 'code');
+    });
+
+    test('should not find comments in plain text or unknown languages', () => {
+      assert.strictEqual(scanCursorSyntax('See https://example.com for ', 'plaintext').kind, 'code');
+      assert.strictEqual(scanCursorSyntax('See https://example.com for ', 'some-new-language').kind, 'code');
+    });
+  });
+});
@@ -0,0 +1,24 @@
+import * as assert from 'assert';
+import { formatGenerationOverrides, parseGenerationOverrides } from '../config/generationOverrides';
+
+suite('generationOverrides Test Suite', () => {
+  test('should parse overrides in either spelling', () => {
+    assert.deepStrictEqual(parseGenerationOverrides('temperature=0.2 top_p=0.9'), { temperature: 0.2, topP: 0.9 });
+    assert.deepStrictEqual(parseGenerationOverrides('Temp=1, top-p=0.5'), { temperature: 1, topP: 0.5 });
+  });
+
+  test('should skip unknown names and invalid values', () => {
+    assert.deepStrictEqual(parseGenerationOverrides('temperature=3 top_p= seed=1 top_p=abc'), {});
+  });
+
+  test('should treat inherited object keys as unknown', () => {
+    assert.deepStrictEqual(parseGenerationOverrides('constructor=1 toString=1 __proto__=1 temperature=0.1'), { temperature: 0.1 });
+  });
+
+  test('should format overrides so they parse back', () => {
+    const overrides = { temperature: 0.4, topP: 0.8 };
+    assert.strictEqual(formatGenerationOverrides(overrides), 'temperature=0.4 top_p=0.8');
+    assert.deepStrictEqual(parseGenerationOverrides(formatGenerationOverrides(overrides)), overrides);
+    assert.strictEqual(formatGenerationOverrides({}), '');
+  });
+});
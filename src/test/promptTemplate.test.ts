@@ -0,0 +1,46 @@
+import * as assert from 'assert';
+import { parsePromptTemplate, PromptTemplateVars, TemplateParseError } from '../inlineCompletionProvider/promptTemplate';
+
+const vars: PromptTemplateVars = {
+  Prefix: 'const a = ',
+  Suffix: ';',
+  Language: 'typescript',
+  Filename: 'a.ts',
+  CurrentLine: 'const a = ',
+  RelatedContext: ''
+};
+
+suite('promptTemplate Test Suite', () => {
+  test('should substitute fields', () => {
+    const template = parsePromptTemplate('<pre>{{.Prefix}}<suf>{{.Suffix}}<mid>');
+    assert.strictEqual(template.render(vars), '<pre>const a = <suf>;<mid>');
+  });
+
+  test('should render the branch chosen by a field', () => {
+    const template = parsePromptTemplate('{{if .RelatedContext}}ctx{{else}}none{{end}} {{.Language}}');
+    assert.strictEqual(template.render(vars), 'none typescript');
+    assert.strictEqual(template.render({ ...vars, RelatedContext: 'x' }), 'ctx typescript');
+  });
+
+  test('should trim whitespace next to trim markers', () => {
+    const template = parsePromptTemplate('a  {{- .Language -}}  \n b');
+    assert.strictEqual(template.render(vars), 'atypescriptb');
+  });
+
+  test('should skip comments', () => {
+    assert.strictEqual(parsePromptTemplate('a{{/* note */}}b').render(vars), 'ab');
+  });
+
+  test('should reject unknown fields with their line', () => {
+    assert.throws(() => parsePromptTemplate('ok\n{{.Prefx}}'), (error: unknown) =>
+      error instanceof TemplateParseError && error.line === 2 && /unknown field \.Prefx/.test(error.message)
+    );
+  });
+
+  test('should reject unbalanced blocks', () => {
+    assert.throws(() => parsePromptTemplate('{{if .Prefix}}a'), TemplateParseError);
+    assert.throws(() => parsePromptTemplate('a{{end}}'), TemplateParseError);
+    assert.throws(() => parsePromptTemplate('{{else}}'), TemplateParseError);
+    assert.throws(() => parsePromptTemplate('a {{.Prefix'), TemplateParseError);
+  });
+});
@@ -0,0 +1,119 @@
+import * as assert from 'assert';
+import {
+  confineToSyntax,
+  dropDuplicateClosers,
+  reindentCompletion,
+  stripPromptEcho,
+  truncateAtSuffix
+} from '../inlineCompletionProvider/responseCleaners';
+
+suite('responseCleaners Test Suite', () => {
+  suite('stripPromptEcho', () => {
+    test('should remove a repeat of the lines before the cursor', () => {
+      const prefix = 'function add(a, b) {\n  return';
+      assert.strictEqual(stripPromptEcho('function add(a, b) {\n  return a + b;', prefix), ' a + b;');
+    });
+
+    test('should find a repeat that is indented differently', () => {
+      const prefix = 'if (ready) {\n    start();\n    ';
+      assert.strictEqual(stripPromptEcho('if (ready) {\n  start();\n  stop();', prefix), '\n  stop();');
+    });
+
+    test('should not cut a completion in the middle of an identifier', () => {
+      const prefix = 'const total = count';
+      assert.strictEqual(stripPromptEcho('ount + 1;', prefix), 'ount + 1;');
+    });
+
+    test('should leave a completion without an echo alone', () => {
+      assert.strictEqual(stripPromptEcho('  return a + b;', 'function add(a, b) {\n'), '  return a + b;');
+    });
+  });
+
+  suite('dropDuplicateClosers', () => {
+    test('should drop closers the suffix already has', () => {
+      assert.strictEqual(dropDuplicateClosers('x)', ')', 'foo('), 'x');
+      assert.strictEqual(dropDuplicateClosers('  return 1;\n}', '}', 'function f() {\n'), '  return 1;');
+    });
+
+    test('should keep closers still needed by brackets the prefix left open', () => {
+      assert.strictEqual(dropDuplicateClosers('x)', ')', 'foo(bar('), 'x)');
+      assert.strictEqual(dropDuplicateClosers('a\n  }\n}', '}', 'class A {\n  m() {'), 'a\n  }');
+    });
+
+    test('should keep closers the suffix does not repeat', () => {
+      assert.strictEqual(dropDuplicateClosers('x)', '', 'foo('), 'x)');
+      assert.strictEqual(dropDuplicateClosers('x]', ')', 'foo('), 'x]');
+    });
+
+    test('should keep closers the completion opened itself', () => {
+      assert.strictEqual(dropDuplicateClosers('bar(x)', ')', 'foo('), 'bar(x)');
+    });
+
+    test('should ignore brackets inside strings', () => {
+      assert.strictEqual(dropDuplicateClosers('")")', ')', 'foo('), '")"');
+    });
+  });
+
+  suite('truncateAtSuffix', () => {
+    test('should cut where the completion repeats the code after the cursor', () => {
+      const suffix = '\n  const result = compute(value);\n  return result;\n}';
+      const completion = 'validate(value);\n  const result = compute(value);\n  return result;\n}';
+      assert.strictEqual(truncateAtSuffix(completion, suffix), 'validate(value);');
+    });
+
+    test('should not treat a line at another indentation as a repeat', () => {
+      const suffix = '\n}';
+      const completion = 'if (x) {\n    y();\n  }';
+      assert.strictEqual(truncateAtSuffix(completion, suffix), completion);
+    });
+
+    test('should not trust a short match while the completion has a block open', () => {
+      const suffix = '\n}';
+      const completion = 'if (x) {\n  y();\n}';
+      assert.strictEqual(truncateAtSuffix(completion, suffix), completion);
+    });
+
+    test('should trust a short match once the completion is balanced', () => {
+      const suffix = '\n}';
+      assert.strictEqual(truncateAtSuffix('y();\n}', suffix), 'y();');
+    });
+
+    test('should leave a completion alone when the suffix is empty', () => {
+      assert.strictEqual(truncateAtSuffix('a\nb', ''), 'a\nb');
+    });
+  });
+
+  suite('reindentCompletion', () => {
+    test('should convert space indentation to tabs', () => {
+      assert.strictEqual(reindentCompletion('{\n    a();\n        b();\n}', '\t', 4), '{\n\ta();\n\t\tb();\n}');
+    });
+
+    test('should convert tab indentation to spaces', () => {
+      assert.strictEqual(reindentCompletion('{\n\ta();\n}', '  ', 2), '{\n  a();\n}');
+    });
+
+    test('should keep the cursor line indentation and convert the rest', () => {
+      assert.strictEqual(reindentCompletion('{\n\t    a();\n\t}', '\t', 4, '\t'), '{\n\t\ta();\n\t}');
+    });
+
+    test('should keep columns that are not a whole level as spaces', () => {
+      assert.strictEqual(reindentCompletion('/**\n     * doc\n     */', '\t', 4), '/**\n\t * doc\n\t */');
+    });
+
+    test('should leave single-line completions and blank lines alone', () => {
+      assert.strictEqual(reindentCompletion('    a();', '\t', 4), '    a();');
+      assert.strictEqual(reindentCompletion('a\n    \nb', '\t', 4), 'a\n    \nb');
+    });
+  });
+
+  suite('confineToSyntax', () => {
+    test('should end a block comment at its closing delimiter', () => {
+      const syntax = { kind: 'comment' as const, multiline: true, close: '*/' };
+      assert.strictEqual(confineToSyntax(' docs\n */\nfunction f() {}', syntax), ' docs\n */');
+    });
+
+    test('should leave code completions alone', () => {
+      assert.strictEqual(confineToSyntax('a\nb', { kind: 'code', multiline: false }), 'a\nb');
+    });
+  });
+});
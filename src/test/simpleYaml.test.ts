@@ -0,0 +1,51 @@
+import * as assert from 'assert';
+import { parseSimpleYaml, YamlParseError } from '../utils/simpleYaml';
+
+suite('simpleYaml Test Suite', () => {
+  test('should parse nested mappings and scalars', () => {
+    const source = [
+      '# project settings',
+      'model: qwen2.5-coder:7b',
+      'contextWindow: 8192',
+      'stripEcho: false',
+      'seed: ~',
+      'profiles:',
+      '  .py:',
+      '    temperature: 0.2',
+      '    model: "deepseek-coder:6.7b" # trailing comment'
+    ].join('\n');
+    assert.deepStrictEqual(parseSimpleYaml(source), {
+      model: 'qwen2.5-coder:7b',
+      contextWindow: 8192,
+      stripEcho: false,
+      seed: null,
+      profiles: { '.py': { temperature: 0.2, model: 'deepseek-coder:6.7b' } }
+    });
+  });
+
+  test('should parse block and inline lists', () => {
+    assert.deepStrictEqual(parseSimpleYaml('stop:\n  - "\\n\\n"\n  - END\nmodels: [a, \'b, c\']'), {
+      stop: ['\n\n', 'END'],
+      models: ['a', 'b, c']
+    });
+  });
+
+  test('should return an empty mapping for an empty document', () => {
+    assert.deepStrictEqual(parseSimpleYaml('\n# nothing\n'), {});
+  });
+
+  test('should report the line of a problem', () => {
+    assert.throws(() => parseSimpleYaml('a: 1\nb: [1, 2'), (error: unknown) =>
+      error instanceof YamlParseError && error.line === 2
+    );
+    assert.throws(() => parseSimpleYaml('a: 1\na: 2'), /duplicate key "a"/);
+    assert.throws(() => parseSimpleYaml('a:\n\t- x'), /tabs/);
+    assert.throws(() => parseSimpleYaml('a: &anchor 1'), YamlParseError);
+  });
+
+  test('should reject keys that would change the prototype', () => {
+    assert.throws(() => parseSimpleYaml('__proto__:\n  model: x'), /reserved key "__proto__"/);
+    assert.throws(() => parseSimpleYaml('profiles:\n  constructor: 1'), YamlParseError);
+    assert.strictEqual(Object.getPrototypeOf(parseSimpleYaml('a: 1')), Object.prototype);
+  });
+});
@@ -0,0 +1,46 @@
+import * as assert from 'assert';
+import {
+  findStopSequence,
+  getDefaultStopSequences,
+  truncateAtStopSequence
+} from '../inlineCompletionProvider/stopSequences';
+
+suite('stopSequences Test Suite', () => {
+  test('should find the earliest stop sequence', () => {
+    assert.strictEqual(findStopSequence('a = 1\n\nb = 2```', ['```', '\n\n']), 5);
+  });
+
+  test('should return -1 when no stop sequence matches', () => {
+    assert.strictEqual(findStopSequence('return x;', ['\n\n', '```']), -1);
+  });
+
+  test('should ignore empty stop sequences', () => {
+    assert.strictEqual(findStopSequence('abc', ['']), -1);
+  });
+
+  test('should skip leading whitespace before matching', () => {
+    assert.strictEqual(findStopSequence('\n\nfoo()\n\nbar()', ['\n\n']), 7);
+    assert.strictEqual(findStopSequence('\nfunc main() {}', getDefaultStopSequences('go')), -1);
+  });
+
+  test('should not stop on text that is only whitespace', () => {
+    assert.strictEqual(findStopSequence('\n\n  ', ['\n\n']), -1);
+  });
+
+  test('should stop at the first line end after text in single-line mode', () => {
+    assert.strictEqual(findStopSequence('\n  foo()\nbar()', [], true), 8);
+    assert.strictEqual(findStopSequence('\n  ', [], true), -1);
+  });
+
+  test('should add language stop sequences to the base ones', () => {
+    const stops = getDefaultStopSequences('python');
+    assert.ok(stops.includes('\n\n'));
+    assert.ok(stops.includes('\ndef '));
+    assert.deepStrictEqual(getDefaultStopSequences('unknown'), getDefaultStopSequences('plaintext'));
+  });
+
+  test('should truncate at the stop sequence and report it', () => {
+    assert.deepStrictEqual(truncateAtStopSequence('x = 1\n\ny = 2', ['\n\n']), { text: 'x = 1', stopped: true });
+    assert.deepStrictEqual(truncateAtStopSequence('x = 1', ['\n\n']), { text: 'x = 1', stopped: false });
+  });
+});
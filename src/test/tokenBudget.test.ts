@@ -0,0 +1,72 @@
+import * as assert from 'assert';
+import {
+  estimateTokens,
+  fitToTokenBudget,
+  getPromptBudget,
+  sizeContextWindow
+} from '../inlineCompletionProvider/tokenBudget';
+
+suite('tokenBudget Test Suite', () => {
+  test('should estimate four characters per token', () => {
+    assert.strictEqual(estimateTokens(''), 0);
+    assert.strictEqual(estimateTokens('12345678'), 2);
+    assert.strictEqual(estimateTokens('123456789'), 3);
+  });
+
+  test('should reserve the generation and overhead out of the context', () => {
+    assert.strictEqual(getPromptBudget(4096, 150, 100), 3846);
+    assert.strictEqual(getPromptBudget(100, 200, 0), 0);
+  });
+
+  test('should size the context window to the request', () => {
+    assert.strictEqual(sizeContextWindow(600, 150, 8192), 1024);
+    assert.strictEqual(sizeContextWindow(600, 150, 8192, 256), 768);
+    assert.strictEqual(sizeContextWindow(10, 10, 8192), 512);
+    assert.strictEqual(sizeContextWindow(9000, 100, 8192), 8192);
+  });
+
+  suite('fitToTokenBudget', () => {
+    const body = Array.from({ length: 50 }, (_, i) => `  value${i} = compute(${i});`).join('\n');
+    const prefix = `function build() {\n${body}\n  return`;
+    const suffix = ' value;\n}\n';
+
+    test('should leave a prompt that fits untouched', () => {
+      assert.deepStrictEqual(fitToTokenBudget('a = 1\nb', '\nc', 100, 'javascript'), {
+        prefix: 'a = 1\nb',
+        suffix: '\nc',
+        trimmed: false
+      });
+    });
+
+    test('should keep the lines nearest the cursor within the budget', () => {
+      const result = fitToTokenBudget(prefix, suffix, 60, 'javascript');
+      assert.strictEqual(result.trimmed, true);
+      assert.ok(result.prefix.endsWith('  value49 = compute(49);\n  return'));
+      assert.ok(estimateTokens(result.prefix) + estimateTokens(result.suffix) <= 60);
+    });
+
+    test('should keep the enclosing signature above an elision marker', () => {
+      const result = fitToTokenBudget(prefix, suffix, 60, 'javascript');
+      assert.ok(result.prefix.startsWith('function build() {\n// ...\n'), result.prefix);
+    });
+
+    test('should use the language comment for the elision marker', () => {
+      const python = `def build():\n${body}\n  return`;
+      const result = fitToTokenBudget(python, '', 60, 'python');
+      assert.ok(result.prefix.startsWith('def build():\n# ...\n'), result.prefix);
+    });
+
+    test('should apply the line window even when the prompt fits', () => {
+      const result = fitToTokenBudget('a\nb\nc\nd', '\ne\nf\ng', 1000, 'plaintext', { linesBefore: 1, linesAfter: 1 });
+      assert.strictEqual(result.prefix, 'c\nd');
+      assert.strictEqual(result.suffix, '\ne');
+      assert.strictEqual(result.trimmed, true);
+    });
+
+    test('should clip an oversized cursor line without splitting a character', () => {
+      // Keeping the last four characters would start inside the emoji
+      const result = fitToTokenBudget('a\nxxxx😀yyy', '', 1, 'plaintext');
+      assert.strictEqual(result.prefix, 'yyy');
+    });
+  });
+});
@@ -117,6 +117,29 @@ export class CancellationManager {
     console.log(`Cancelled operation ${id}: ${reason || 'User requested'}`);
   }
 
+  /**
+   * Removes a finished operation's token without cancelling it.
+   * When a token is given, only releases if it is still the active one for the ID.
+   */
+  release(id: string, token?: vscode.CancellationToken): boolean {
+    const managedToken = this.tokens.get(id);
+    if (!managedToken || (token && managedToken.token !== token)) {
+      return false;
+    }
+
+    if (managedToken.timeout) {
+      clearTimeout(managedToken.timeout);
+    }
+
+    if (managedToken.parent) {
+      this.tokens.get(managedToken.parent)?.children.delete(id);
+    }
+
+    managedToken.source.dispose();
+    this.tokens.delete(id);
+    return true;
+  }
+
   /**
    * Cancels all tokens
    */
@@ -0,0 +1,154 @@
+/**
+ * Prometheus metrics for inline completions
+ */
+
+import * as vscode from 'vscode';
+import { promises as fs } from 'fs';
+import { Logger } from './logger';
+import { MetricsRegistry } from './PrometheusMetrics';
+
+/**
+ * How often the textfile is rewritten while metrics are enabled
+ */
+const TEXTFILE_INTERVAL = 15000; // ms
+
+/**
+ * Outcome of a single completion request
+ */
+export interface CompletionSample {
+  model?: string;
+  finishReason: string;
+  cached?: boolean;
+  upstreamError?: boolean;
+  latencyMs: number;
+  promptTokens?: number;
+  completionTokens?: number;
+}
+
+/**
+ * Collects completion counters and histograms labelled by model. Nothing is
+ * recorded until enabled, and the metrics can also be written to a file for
+ * the node_exporter textfile collector.
+ */
+export class CompletionMetrics implements vscode.Disposable {
+  private enabled = false;
+  private textfilePath = '';
+  private timer: NodeJS.Timeout | undefined;
+
+  private readonly registry = new MetricsRegistry();
+  private readonly completions = this.registry.counter(
+    'ollama_copilot_completions_total',
+    'Completion requests by model and finish reason'
+  );
+  private readonly cacheHits = this.registry.counter(
+    'ollama_copilot_cache_hits_total',
+    'Completions served from the completion cache'
+  );
+  private readonly cacheMisses = this.registry.counter(
+    'ollama_copilot_cache_misses_total',
+    'Completions that required a model call'
+  );
+  private readonly upstreamErrors = this.registry.counter(
+    'ollama_copilot_upstream_errors_total',
+    'Completions that failed with an Ollama API error'
+  );
+  private readonly latency = this.registry.histogram(
+    'ollama_copilot_completion_latency_seconds',
+    'End-to-end latency of completions that called the model',
+    [0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30]
+  );
+  private readonly tokens = this.registry.histogram(
+    'ollama_copilot_completion_tokens',
+    'Tokens generated per completion',
+    [8, 16, 32, 64, 128, 256, 512, 1024]
+  );
+  private readonly promptTokens = this.registry.histogram(
+    'ollama_copilot_prompt_tokens',
+    'Prompt tokens evaluated per completion',
+    [64, 128, 256, 512, 1024, 2048, 4096, 8192]
+  );
+
+  /**
+   * Applies the metrics settings. The textfile must end in .prom, as the
+   * node_exporter collector expects, so the periodic replace can never
+   * overwrite some other file.
+   */
+  configure(enabled: boolean, textfilePath: string): void {
+    if (!enabled) {
+      this.registry.reset();
+    }
+    this.enabled = enabled;
+    this.textfilePath = textfilePath;
+
+    if (this.timer) {
+      clearInterval(this.timer);
+      this.timer = undefined;
+    }
+    if (enabled && textfilePath && !textfilePath.endsWith('.prom')) {
+      Logger.warn('CompletionMetrics', `Not writing metrics to ${textfilePath}: the file name must end in .prom`);
+    } else if (enabled && textfilePath) {
+      this.timer = setInterval(() => void this.writeTextfile(), TEXTFILE_INTERVAL);
+    }
+  }
+
+  isEnabled(): boolean {
+    return this.enabled;
+  }
+
+  /**
+   * Records a finished completion
+   */
+  record(sample: CompletionSample): void {
+    if (!this.enabled) {
+      return;
+    }
+
+    const model = sample.model || 'none';
+    this.completions.inc({ model, finish_reason: sample.finishReason });
+
+    if (sample.cached === true) {
+      this.cacheHits.inc({ model });
+    } else if (sample.cached === false) {
+      this.cacheMisses.inc({ model });
+      this.latency.observe({ model }, sample.latencyMs / 1000);
+    }
+    if (sample.upstreamError) {
+      this.upstreamErrors.inc({ model });
+    }
+    // Prompt sizes are recorded only when Ollama reported the counts
+    if (sample.completionTokens !== undefined) {
+      this.tokens.observe({ model }, sample.completionTokens);
+      if (sample.promptTokens !== undefined) {
+        this.promptTokens.observe({ model }, sample.promptTokens);
+      }
+    }
+  }
+
+  /**
+   * Renders the metrics, or undefined when collection is disabled
+   */
+  render(): string | undefined {
+    return this.enabled ? this.registry.render() : undefined;
+  }
+
+  dispose(): void {
+    if (this.timer) {
+      clearInterval(this.timer);
+      this.timer = undefined;
+    }
+  }
+
+  /**
+   * Writes through a temporary file so a scrape never reads a partial file
+   */
+  private async writeTextfile(): Promise<void> {
+    const target = this.textfilePath;
+    const temp = `${target}.${process.pid}.tmp`;
+    try {
+      await fs.writeFile(temp, this.registry.render(), 'utf8');
+      await fs.rename(temp, target);
+    } catch (error) {
+      Logger.warn('CompletionMetrics', `Failed to write metrics to ${target}: ${error instanceof Error ? error.message : String(error)}`);
+    }
+  }
+}
@@ -0,0 +1,218 @@
+/**
+ * Disk-backed cache that survives restarts
+ */
+
+import * as crypto from 'crypto';
+import * as path from 'path';
+import { promises as fs } from 'fs';
+import { Logger } from './logger';
+
+/**
+ * Stored form of a cache entry
+ */
+interface DiskCacheRecord<V> {
+  key: string;
+  value: V;
+  storedAt: number;
+}
+
+/**
+ * Subdirectory of the configured directory that holds the entries, so
+ * clearing or evicting never touches files the cache did not write
+ */
+const ENTRY_DIRECTORY = 'ollama-copilot-cache';
+
+/**
+ * Names of entry files, as produced by fileName
+ */
+const ENTRY_NAME = /^[0-9a-f]{64}\.json$/;
+
+/**
+ * Size and age of an entry on disk, used for eviction
+ */
+interface DiskCacheIndexEntry {
+  size: number;
+  lastUsed: number;
+}
+
+/**
+ * Cache stored as one JSON file per entry, named by the hash of its key,
+ * in its own subdirectory of the configured directory. Only files named
+ * like entries are indexed, so a directory shared with other files is
+ * never emptied by clear or eviction. Nothing is read at startup: the index of files is built on first use,
+ * entries are read only when requested, and the least recently used files
+ * are removed once the directory grows past its size limit. Unreadable or
+ * corrupt entries count as misses and are deleted.
+ */
+export class DiskCache<V> {
+  private directory: string | undefined;
+  private maxBytes = 0;
+  private ttl = 0;
+  private index: Promise<Map<string, DiskCacheIndexEntry>> | undefined;
+  private totalBytes = 0;
+
+  /**
+   * Applies the cache settings. An empty directory disables the cache.
+   */
+  configure(directory: string | undefined, maxBytes: number, ttl: number): void {
+    directory = directory ? path.join(directory, ENTRY_DIRECTORY) : undefined;
+    if (directory !== this.directory) {
+      this.index = undefined;
+      this.totalBytes = 0;
+    }
+    this.directory = directory;
+    this.maxBytes = Math.max(0, maxBytes);
+    this.ttl = Math.max(0, ttl);
+  }
+
+  isEnabled(): boolean {
+    return !!this.directory && this.maxBytes > 0;
+  }
+
+  /**
+   * Reads an entry, or undefined when it is missing, expired or corrupt
+   */
+  async get(key: string): Promise<V | undefined> {
+    const directory = this.directory;
+    if (!directory || !this.isEnabled()) {
+      return undefined;
+    }
+
+    const index = await this.loadIndex(directory);
+    const name = this.fileName(key);
+    const entry = index.get(name);
+    if (!entry) {
+      return undefined;
+    }
+
+    const file = path.join(directory, name);
+    try {
+      const record = JSON.parse(await fs.readFile(file, 'utf8')) as DiskCacheRecord<V>;
+      if (record.key !== key || typeof record.storedAt !== 'number') {
+        throw new Error('entry does not match its key');
+      }
+      if (this.ttl > 0 && Date.now() - record.storedAt > this.ttl) {
+        await this.remove(index, directory, name);
+        return undefined;
+      }
+
+      entry.lastUsed = Date.now();
+      const now = new Date();
+      fs.utimes(file, now, now).catch(() => undefined);
+      return record.value;
+    } catch (error) {
+      Logger.debug('DiskCache', `Skipping corrupt cache entry ${name}: ${error instanceof Error ? error.message : String(error)}`);
+      await this.remove(index, directory, name);
+      return undefined;
+    }
+  }
+
+  /**
+   * Writes an entry, evicting the oldest entries when over the size limit
+   */
+  async set(key: string, value: V): Promise<void> {
+    const directory = this.directory;
+    if (!directory || !this.isEnabled()) {
+      return;
+    }
+
+    const index = await this.loadIndex(directory);
+    const name = this.fileName(key);
+    const record: DiskCacheRecord<V> = { key, value, storedAt: Date.now() };
+    const data = JSON.stringify(record);
+    const file = path.join(directory, name);
+    const temp = `${file}.${process.pid}.tmp`;
+
+    try {
+      await fs.writeFile(temp, data, 'utf8');
+      await fs.rename(temp, file);
+    } catch (error) {
+      Logger.warn('DiskCache', `Failed to write cache entry to ${directory}: ${error instanceof Error ? error.message : String(error)}`);
+      fs.unlink(temp).catch(() => undefined);
+      return;
+    }
+
+    const size = Buffer.byteLength(data);
+    this.totalBytes += size - (index.get(name)?.size ?? 0);
+    index.set(name, { size, lastUsed: Date.now() });
+    await this.evict(index, directory);
+  }
+
+  /**
+   * Deletes every entry
+   */
+  async clear(): Promise<void> {
+    const directory = this.directory;
+    if (!directory) {
+      return;
+    }
+
+    const index = await this.loadIndex(directory);
+    await Promise.all([...index.keys()].map(name => fs.unlink(path.join(directory, name)).catch(() => undefined)));
+    index.clear();
+    this.totalBytes = 0;
+  }
+
+  /**
+   * Scans the directory once, on first use
+   */
+  private loadIndex(directory: string): Promise<Map<string, DiskCacheIndexEntry>> {
+    if (!this.index) {
+      this.index = this.scan(directory);
+    }
+    return this.index;
+  }
+
+  private async scan(directory: string): Promise<Map<string, DiskCacheIndexEntry>> {
+    const index = new Map<string, DiskCacheIndexEntry>();
+    this.totalBytes = 0;
+
+    try {
+      await fs.mkdir(directory, { recursive: true });
+      for (const name of await fs.readdir(directory)) {
+        if (!ENTRY_NAME.test(name)) {
+          continue;
+        }
+        try {
+          const stat = await fs.stat(path.join(directory, name));
+          index.set(name, { size: stat.size, lastUsed: stat.mtimeMs });
+          this.totalBytes += stat.size;
+        } catch {
+          // Removed while scanning
+        }
+      }
+      Logger.debug('DiskCache', `Indexed ${index.size} cache entries in ${directory}`);
+    } catch (error) {
+      Logger.warn('DiskCache', `Cannot use cache directory ${directory}: ${error instanceof Error ? error.message : String(error)}`);
+    }
+
+    return index;
+  }
+
+  private async evict(index: Map<string, DiskCacheIndexEntry>, directory: string): Promise<void> {
+    if (this.totalBytes <= this.maxBytes) {
+      return;
+    }
+
+    const oldestFirst = [...index.entries()].sort((a, b) => a[1].lastUsed - b[1].lastUsed);
+    for (const [name] of oldestFirst) {
+      if (this.totalBytes <= this.maxBytes) {
+        break;
+      }
+      await this.remove(index, directory, name);
+    }
+  }
+
+  private async remove(index: Map<string, DiskCacheIndexEntry>, directory: string, name: string): Promise<void> {
+    const entry = index.get(name);
+    if (entry) {
+      index.delete(name);
+      this.totalBytes -= entry.size;
+    }
+    await fs.unlink(path.join(directory, name)).catch(() => undefined);
+  }
+
+  private fileName(key: string): string {
+    return `${crypto.createHash('sha256').update(key).digest('hex')}.json`;
+  }
+}
@@ -11,6 +11,9 @@ export interface RetryOptions {
   factor?: number;
   onRetry?: (attempt: number, error: Error) => void;
   shouldRetry?: (error: Error) => boolean;
+  token?: vscode.CancellationToken;
+  /** Time in ms since the epoch after which no retry is started */
+  deadline?: number;
 }
 
 export interface ErrorRecoveryOptions {
@@ -24,7 +27,7 @@ export interface ErrorRecoveryOptions {
  * Implements exponential backoff retry logic
  */
 export class ExponentialBackoff {
-  private readonly defaultOptions: Required<RetryOptions> = {
+  private readonly defaultOptions: Required<Omit<RetryOptions, 'token' | 'deadline'>> = {
     maxAttempts: 3,
     initialDelay: 1000,
     maxDelay: 30000,
@@ -63,21 +66,28 @@ export class ExponentialBackoff {
       } catch (error) {
         lastError = error as Error;
 
-        // Check if we should retry
-        if (!opts.shouldRetry(lastError)) {
+        // Check if we should retry, never once the caller has given up
+        if (opts.token?.isCancellationRequested || !opts.shouldRetry(lastError)) {
           throw lastError;
         }
 
-        // Don't retry if it's the last attempt
+        // Don't retry if it's the last attempt, or if the wait would
+        // outlast the deadline
         if (attempt === opts.maxAttempts) {
           throw lastError;
         }
+        if (opts.deadline !== undefined && Date.now() + delay >= opts.deadline) {
+          throw lastError;
+        }
 
         // Call retry callback
         opts.onRetry(attempt, lastError);
 
         // Wait before retrying
-        await this.delay(delay);
+        await this.delay(delay, opts.token);
+        if (opts.token?.isCancellationRequested) {
+          throw lastError;
+        }
 
         // Calculate next delay
         delay = Math.min(delay * opts.factor, opts.maxDelay);
@@ -88,10 +98,20 @@ export class ExponentialBackoff {
   }
 
   /**
-   * Delays execution
+   * Delays execution, resolving early if the token is cancelled
    */
-  private delay(ms: number): Promise<void> {
-    return new Promise(resolve => setTimeout(resolve, ms));
+  private delay(ms: number, token?: vscode.CancellationToken): Promise<void> {
+    return new Promise(resolve => {
+      const timer = setTimeout(() => {
+        subscription?.dispose();
+        resolve();
+      }, ms);
+      const subscription = token?.onCancellationRequested(() => {
+        clearTimeout(timer);
+        subscription?.dispose();
+        resolve();
+      });
+    });
   }
 }
 
@@ -0,0 +1,134 @@
+/**
+ * Minimal metrics registry rendered in the Prometheus text exposition format
+ */
+
+export type MetricLabels = Record<string, string>;
+
+interface LabeledValue<T> {
+  labels: MetricLabels;
+  value: T;
+}
+
+interface HistogramValue {
+  counts: number[];
+  sum: number;
+  count: number;
+}
+
+/**
+ * Serializes labels in a stable order so equal label sets share a series
+ */
+function labelKey(labels: MetricLabels): string {
+  return Object.keys(labels).sort().map(key => `${key}=${labels[key]}`).join(',');
+}
+
+function formatLabels(labels: MetricLabels): string {
+  const entries = Object.keys(labels).sort().map(key =>
+    `${key}="${labels[key].replace(/\\/g, '\\\\').replace(/"/g, '\\"').replace(/\n/g, '\\n')}"`
+  );
+  return entries.length > 0 ? `{${entries.join(',')}}` : '';
+}
+
+/**
+ * Monotonic counter with labels
+ */
+export class Counter {
+  private readonly series = new Map<string, LabeledValue<number>>();
+
+  constructor(readonly name: string, readonly help: string) {}
+
+  inc(labels: MetricLabels = {}, amount = 1): void {
+    const key = labelKey(labels);
+    const entry = this.series.get(key);
+    if (entry) {
+      entry.value += amount;
+    } else {
+      this.series.set(key, { labels, value: amount });
+    }
+  }
+
+  render(): string[] {
+    const lines = [`# HELP ${this.name} ${this.help}`, `# TYPE ${this.name} counter`];
+    for (const { labels, value } of this.series.values()) {
+      lines.push(`${this.name}${formatLabels(labels)} ${value}`);
+    }
+    return lines;
+  }
+
+  reset(): void {
+    this.series.clear();
+  }
+}
+
+/**
+ * Histogram with fixed upper bounds and labels
+ */
+export class Histogram {
+  private readonly series = new Map<string, LabeledValue<HistogramValue>>();
+
+  constructor(readonly name: string, readonly help: string, private readonly buckets: number[]) {}
+
+  observe(labels: MetricLabels, value: number): void {
+    const key = labelKey(labels);
+    let entry = this.series.get(key);
+    if (!entry) {
+      entry = { labels, value: { counts: this.buckets.map(() => 0), sum: 0, count: 0 } };
+      this.series.set(key, entry);
+    }
+
+    this.buckets.forEach((bound, index) => {
+      if (value <= bound) {
+        entry!.value.counts[index]++;
+      }
+    });
+    entry.value.sum += value;
+    entry.value.count++;
+  }
+
+  render(): string[] {
+    const lines = [`# HELP ${this.name} ${this.help}`, `# TYPE ${this.name} histogram`];
+    for (const { labels, value } of this.series.values()) {
+      this.buckets.forEach((bound, index) => {
+        lines.push(`${this.name}_bucket${formatLabels({ ...labels, le: String(bound) })} ${value.counts[index]}`);
+      });
+      lines.push(`${this.name}_bucket${formatLabels({ ...labels, le: '+Inf' })} ${value.count}`);
+      lines.push(`${this.name}_sum${formatLabels(labels)} ${value.sum}`);
+      lines.push(`${this.name}_count${formatLabels(labels)} ${value.count}`);
+    }
+    return lines;
+  }
+
+  reset(): void {
+    this.series.clear();
+  }
+}
+
+/**
+ * Collection of metrics rendered together
+ */
+export class MetricsRegistry {
+  private readonly metrics: Array<Counter | Histogram> = [];
+
+  counter(name: string, help: string): Counter {
+    const counter = new Counter(name, help);
+    this.metrics.push(counter);
+    return counter;
+  }
+
+  histogram(name: string, help: string, buckets: number[]): Histogram {
+    const histogram = new Histogram(name, help, buckets);
+    this.metrics.push(histogram);
+    return histogram;
+  }
+
+  /**
+   * Renders every metric in the Prometheus text format
+   */
+  render(): string {
+    return this.metrics.map(metric => metric.render().join('\n')).join('\n\n') + '\n';
+  }
+
+  reset(): void {
+    this.metrics.forEach(metric => metric.reset());
+  }
+}
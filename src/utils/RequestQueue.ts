@@ -0,0 +1,117 @@
+/**
+ * Concurrency limiting for upstream requests
+ */
+
+import * as vscode from 'vscode';
+
+/**
+ * Thrown when a request arrives while the queue is already at its maximum depth
+ */
+export class QueueFullError extends Error {
+  constructor(maxQueueDepth: number) {
+    super(`Request queue is full (${maxQueueDepth} waiting)`);
+    this.name = 'QueueFullError';
+  }
+}
+
+interface QueuedRequest {
+  resolve: () => void;
+  reject: (error: Error) => void;
+  cancellation?: vscode.Disposable;
+}
+
+/**
+ * Semaphore that runs at most a fixed number of operations at once and
+ * queues the rest in arrival order. A queued request whose token is
+ * cancelled leaves the queue without ever running.
+ */
+export class RequestQueue implements vscode.Disposable {
+  private active = 0;
+  private readonly waiting: QueuedRequest[] = [];
+
+  constructor(
+    private maxConcurrent: number,
+    private maxQueueDepth: number
+  ) {}
+
+  /**
+   * Updates the limits, starting queued requests if there is new capacity
+   */
+  configure(maxConcurrent: number, maxQueueDepth: number): void {
+    this.maxConcurrent = Math.max(1, maxConcurrent);
+    this.maxQueueDepth = Math.max(0, maxQueueDepth);
+    this.drain();
+  }
+
+  /**
+   * Runs an operation once a slot is free
+   */
+  async run<T>(operation: () => Promise<T>, token?: vscode.CancellationToken): Promise<T> {
+    await this.acquire(token);
+    try {
+      return await operation();
+    } finally {
+      this.active--;
+      this.drain();
+    }
+  }
+
+  /**
+   * Gets the current load
+   */
+  getStats(): { active: number; queued: number; maxConcurrent: number; maxQueueDepth: number } {
+    return {
+      active: this.active,
+      queued: this.waiting.length,
+      maxConcurrent: this.maxConcurrent,
+      maxQueueDepth: this.maxQueueDepth
+    };
+  }
+
+  /**
+   * Rejects every queued request
+   */
+  dispose(): void {
+    for (const request of this.waiting.splice(0)) {
+      request.cancellation?.dispose();
+      request.reject(new Error('Request queue disposed'));
+    }
+  }
+
+  private acquire(token?: vscode.CancellationToken): Promise<void> {
+    if (token?.isCancellationRequested) {
+      return Promise.reject(new Error('Request cancelled while queued'));
+    }
+
+    if (this.active < this.maxConcurrent) {
+      this.active++;
+      return Promise.resolve();
+    }
+
+    if (this.waiting.length >= this.maxQueueDepth) {
+      return Promise.reject(new QueueFullError(this.maxQueueDepth));
+    }
+
+    return new Promise((resolve, reject) => {
+      const request: QueuedRequest = { resolve, reject };
+      request.cancellation = token?.onCancellationRequested(() => {
+        const index = this.waiting.indexOf(request);
+        if (index !== -1) {
+          this.waiting.splice(index, 1);
+          reject(new Error('Request cancelled while queued'));
+        }
+        request.cancellation?.dispose();
+      });
+      this.waiting.push(request);
+    });
+  }
+
+  private drain(): void {
+    while (this.active < this.maxConcurrent && this.waiting.length > 0) {
+      const next = this.waiting.shift()!;
+      next.cancellation?.dispose();
+      this.active++;
+      next.resolve();
+    }
+  }
+}
@@ -121,6 +121,19 @@ export class ApiNotAvailableError extends NetworkError {
   }
 }
 
+/**
+ * Error from an Ollama API call, recording whether it looked transient
+ */
+export class OllamaApiError extends NetworkError {
+  constructor(
+    message: string,
+    public readonly status?: number,
+    public readonly transient: boolean = false
+  ) {
+    super(message, ErrorCodes.API_ERROR, { status });
+  }
+}
+
 /**
  * File system errors
  */
@@ -453,6 +466,26 @@ export function isRetryable(error: Error): boolean {
          message.includes('temporarily');
 }
 
+/**
+ * Check if a raw Ollama client error is worth retrying: dropped or refused
+ * connections, gateway and server errors, and models still loading. Client
+ * errors such as a bad request or an unknown model are not.
+ */
+export function isTransientError(error: unknown): boolean {
+  if (!(error instanceof Error) || error.name === 'AbortError') {
+    return false;
+  }
+  
+  const status = (error as { status_code?: number }).status_code;
+  if (status !== undefined) {
+    return status === 408 || status === 429 || status >= 500;
+  }
+  
+  const cause = (error as { cause?: { code?: string } }).cause;
+  const text = `${error.message} ${cause?.code ?? ''}`.toLowerCase();
+  return /econnrefused|econnreset|epipe|etimedout|socket hang up|fetch failed|terminated|loading model|model is loading/.test(text);
+}
+
 /**
  * Get retry configuration for an error
  */
@@ -0,0 +1,174 @@
+/**
+ * HTTP transport for the Ollama client
+ */
+
+import * as http from 'http';
+import * as https from 'https';
+import { Readable } from 'stream';
+
+/**
+ * Connection settings for requests to Ollama
+ */
+export interface HttpClientOptions {
+  verifyTls: boolean;
+  connectTimeout: number;
+  maxIdleConnections: number;
+}
+
+/**
+ * A fetch implementation over Node's http and https modules with a
+ * keep-alive connection pool, so completions reuse open connections instead
+ * of dialing, and for TLS, handshaking, on every request. A connection that
+ * is not established within the connect timeout fails with ETIMEDOUT, which
+ * the retry logic treats as transient.
+ */
+export class HttpClient {
+  private readonly httpAgent: http.Agent;
+  private readonly httpsAgent: https.Agent;
+  private openRequests = 0;
+  private disposed = false;
+
+  constructor(private readonly options: HttpClientOptions) {
+    const pool = { keepAlive: true, maxFreeSockets: Math.max(1, options.maxIdleConnections) };
+    this.httpAgent = new http.Agent(pool);
+    this.httpsAgent = new https.Agent({ ...pool, rejectUnauthorized: options.verifyTls });
+  }
+
+  /**
+   * A fetch that also aborts when the given signal does, for clients that
+   * do not let the caller pass a signal of their own. The request is torn
+   * down as soon as the signal fires, even before the response headers
+   * arrive.
+   */
+  withSignal(signal: AbortSignal): (input: string | URL | Request, init?: RequestInit) => Promise<Response> {
+    return (input, init) => this.fetch(input, {
+      ...init,
+      signal: init?.signal ? AbortSignal.any([init.signal, signal]) : signal
+    });
+  }
+
+  readonly fetch = (input: string | URL | Request, init?: RequestInit): Promise<Response> => {
+    const url = new URL(typeof input === 'string' || input instanceof URL ? input : input.url);
+    const secure = url.protocol === 'https:';
+    const headers: Record<string, string> = {};
+    new Headers(init?.headers).forEach((value, key) => { headers[key] = value; });
+
+    return new Promise<Response>((resolve, reject) => {
+      const signal = init?.signal;
+      if (signal?.aborted) {
+        reject(abortError());
+        return;
+      }
+
+      let body: string | Uint8Array | undefined;
+      try {
+        body = toRequestBody(init?.body);
+      } catch (error) {
+        reject(error);
+        return;
+      }
+
+      const request = (secure ? https : http).request(url, {
+        method: init?.method || 'GET',
+        headers,
+        agent: secure ? this.httpsAgent : this.httpAgent
+      });
+      this.openRequests++;
+      request.once('close', () => {
+        this.openRequests--;
+        if (this.disposed && this.openRequests === 0) {
+          this.destroyAgents();
+        }
+      });
+
+      // Aborting tears down the response stream too, so a streamed
+      // generation stops mid-body
+      let incoming: http.IncomingMessage | undefined;
+      const onAbort = (): void => {
+        const error = abortError();
+        incoming?.destroy(error);
+        request.destroy(error);
+      };
+      const cleanup = (): void => signal?.removeEventListener('abort', onAbort);
+      signal?.addEventListener('abort', onAbort, { once: true });
+
+      request.on('socket', socket => {
+        if (!socket.connecting || this.options.connectTimeout <= 0) {
+          return;
+        }
+        const timer = setTimeout(() => {
+          const error = new Error(`Connecting to ${url.host} timed out after ${this.options.connectTimeout}ms`) as NodeJS.ErrnoException;
+          error.code = 'ETIMEDOUT';
+          request.destroy(error);
+        }, this.options.connectTimeout);
+        socket.once(secure ? 'secureConnect' : 'connect', () => clearTimeout(timer));
+        socket.once('close', () => clearTimeout(timer));
+      });
+
+      request.on('response', response => {
+        incoming = response;
+        response.once('close', cleanup);
+        const responseHeaders = new Headers();
+        for (const [key, value] of Object.entries(response.headers)) {
+          if (value !== undefined) {
+            responseHeaders.set(key, Array.isArray(value) ? value.join(', ') : value);
+          }
+        }
+        const status = response.statusCode ?? 500;
+        const body = status === 204 || status === 304
+          ? null
+          : Readable.toWeb(response) as unknown as ReadableStream<Uint8Array>;
+        resolve(new Response(body, { status, statusText: response.statusMessage, headers: responseHeaders }));
+      });
+
+      request.on('error', error => {
+        cleanup();
+        reject(error);
+      });
+      request.end(body);
+    });
+  };
+
+  /**
+   * Closes pooled connections once the requests still open have finished,
+   * so replacing the client does not cut off a completion mid-stream
+   */
+  dispose(): void {
+    this.disposed = true;
+    if (this.openRequests === 0) {
+      this.destroyAgents();
+    }
+  }
+
+  private destroyAgents(): void {
+    this.httpAgent.destroy();
+    this.httpsAgent.destroy();
+  }
+}
+
+/**
+ * Converts a fetch body to what a Node request can write. Only the body
+ * types the Ollama client sends are supported; streams and form data throw
+ * rather than being sent as an empty body.
+ */
+function toRequestBody(body: RequestInit['body']): string | Uint8Array | undefined {
+  if (body === undefined || body === null) {
+    return undefined;
+  }
+  if (typeof body === 'string') {
+    return body;
+  }
+  if (body instanceof ArrayBuffer) {
+    return new Uint8Array(body);
+  }
+  if (ArrayBuffer.isView(body)) {
+    return new Uint8Array(body.buffer, body.byteOffset, body.byteLength);
+  }
+  throw new TypeError(`Unsupported request body type: ${Object.prototype.toString.call(body)}`);
+}
+
+function abortError(): Error {
+  const error = new Error('The operation was aborted');
+  error.name = 'AbortError';
+  return error;
+}
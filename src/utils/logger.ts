@@ -4,11 +4,38 @@
 
 import * as vscode from 'vscode';
 
+/**
+ * Log verbosity, from least to most verbose
+ */
+export type LogLevel = 'error' | 'warn' | 'info' | 'debug';
+
+const LOG_LEVEL_ORDER: Record<LogLevel, number> = {
+  error: 0,
+  warn: 1,
+  info: 2,
+  debug: 3
+};
+
 /**
  * Logger class that writes to VS Code output channel
  */
 export class Logger {
   private static outputChannel: vscode.OutputChannel | undefined;
+  private static level: LogLevel = 'info';
+  
+  /**
+   * Set the most verbose level that is written
+   */
+  static setLevel(level: string): void {
+    this.level = level in LOG_LEVEL_ORDER ? level as LogLevel : 'info';
+  }
+  
+  /**
+   * Check whether messages at a level are written
+   */
+  static isEnabled(level: LogLevel): boolean {
+    return LOG_LEVEL_ORDER[level] <= LOG_LEVEL_ORDER[this.level];
+  }
   
   /**
    * Initialize the logger with an output channel
@@ -37,6 +64,7 @@ export class Logger {
    * Log an info message
    */
   static info(context: string, message: string, ...args: any[]): void {
+    if (!this.isEnabled('info')) {return;}
     const timestamp = new Date().toISOString();
     const formattedMessage = `[${timestamp}] [INFO] [${context}] ${message}`;
     
@@ -51,6 +79,7 @@ export class Logger {
    * Log an error message
    */
   static error(context: string, message: string, error?: any): void {
+    if (!this.isEnabled('error')) {return;}
     const timestamp = new Date().toISOString();
     const formattedMessage = `[${timestamp}] [ERROR] [${context}] ${message}`;
     
@@ -72,6 +101,7 @@ export class Logger {
    * Log a warning message
    */
   static warn(context: string, message: string, ...args: any[]): void {
+    if (!this.isEnabled('warn')) {return;}
     const timestamp = new Date().toISOString();
     const formattedMessage = `[${timestamp}] [WARN] [${context}] ${message}`;
     
@@ -86,6 +116,7 @@ export class Logger {
    * Log a debug message
    */
   static debug(context: string, message: string, ...args: any[]): void {
+    if (!this.isEnabled('debug')) {return;}
     const timestamp = new Date().toISOString();
     const formattedMessage = `[${timestamp}] [DEBUG] [${context}] ${message}`;
     
@@ -95,4 +126,18 @@ export class Logger {
       this.getChannel().appendLine(formattedMessage);
     }
   }
+  
+  /**
+   * Log a structured event as a single JSON line
+   */
+  static event(context: string, event: string, fields: Record<string, unknown>, level: LogLevel = 'info'): void {
+    if (!this.isEnabled(level)) {return;}
+    this.getChannel().appendLine(JSON.stringify({
+      timestamp: new Date().toISOString(),
+      level,
+      context,
+      event,
+      ...fields
+    }));
+  }
 }
\ No newline at end of file
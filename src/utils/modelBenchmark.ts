@@ -0,0 +1,177 @@
+/**
+ * Completion latency benchmark across models
+ */
+
+import * as vscode from 'vscode';
+import { IOllamaApiService, GenerationStats } from '../services/interfaces/IOllamaApiService';
+import {
+  generatePromptFromContext,
+  generateFimPrompt,
+  getFimTemplate
+} from '../inlineCompletionProvider/promptGenerators';
+
+/**
+ * Tokens generated per benchmark request, about one block completion
+ */
+const BENCHMARK_MAX_TOKENS = 64;
+
+/**
+ * Code around a cursor, as the completion provider would send it
+ */
+interface BenchmarkPrompt {
+  name: string;
+  language: string;
+  prefix: string;
+  suffix: string;
+}
+
+/**
+ * Representative completions: an inline expression, a function body and a
+ * block between existing code
+ */
+export const BENCHMARK_PROMPTS: ReadonlyArray<BenchmarkPrompt> = [
+  {
+    name: 'inline',
+    language: 'typescript',
+    prefix: 'const users = await fetchUsers();\nconst activeUsers = users.filter(',
+    suffix: ');\nconsole.log(activeUsers.length);\n'
+  },
+  {
+    name: 'function',
+    language: 'python',
+    prefix: 'def fibonacci(n: int) -> int:\n    """Return the nth Fibonacci number."""\n    ',
+    suffix: '\n\n\nif __name__ == "__main__":\n    print(fibonacci(10))\n'
+  },
+  {
+    name: 'block',
+    language: 'go',
+    prefix: 'func readLines(path string) ([]string, error) {\n\tfile, err := os.Open(path)\n\tif err != nil {\n\t\treturn nil, err\n\t}\n\tdefer file.Close()\n\n\t',
+    suffix: '\n}\n'
+  }
+];
+
+/**
+ * Timing of one prompt against one model
+ */
+export interface BenchmarkResult {
+  model: string;
+  prompt: string;
+  timeToFirstTokenMs?: number;
+  totalMs: number;
+  completionTokens?: number;
+  tokensPerSecond?: number;
+  error?: string;
+}
+
+/**
+ * Runs every benchmark prompt against each model in turn. Each model is
+ * loaded before it is timed so the first prompt does not include the load.
+ * Prompts are built the way completions build them, with FIM tokens for
+ * models that support them.
+ */
+export async function benchmarkModels(
+  apiService: IOllamaApiService,
+  models: string[],
+  token?: vscode.CancellationToken,
+  onProgress?: (model: string, prompt: string) => void
+): Promise<BenchmarkResult[]> {
+  const results: BenchmarkResult[] = [];
+
+  for (const model of models) {
+    try {
+      await apiService.loadModel(model);
+    } catch (error) {
+      results.push({ model, prompt: '-', totalMs: 0, error: error instanceof Error ? error.message : String(error) });
+      continue;
+    }
+
+    for (const prompt of BENCHMARK_PROMPTS) {
+      if (token?.isCancellationRequested) {
+        return results;
+      }
+      onProgress?.(model, prompt.name);
+      results.push(await runPrompt(apiService, model, prompt, token));
+    }
+  }
+
+  return results;
+}
+
+async function runPrompt(
+  apiService: IOllamaApiService,
+  model: string,
+  prompt: BenchmarkPrompt,
+  token?: vscode.CancellationToken
+): Promise<BenchmarkResult> {
+  const fimTemplate = getFimTemplate(model);
+  const text = fimTemplate
+    ? generateFimPrompt(prompt, fimTemplate)
+    : generatePromptFromContext({
+        prefix: prompt.prefix,
+        suffix: prompt.suffix,
+        currentLine: prompt.prefix.substring(prompt.prefix.lastIndexOf('\n') + 1),
+        language: prompt.language
+      });
+
+  const startTime = Date.now();
+  let firstTokenAt: number | undefined;
+  let stats: GenerationStats | undefined;
+
+  try {
+    await apiService.generateStream(
+      {
+        model,
+        prompt: text,
+        raw: fimTemplate ? true : undefined,
+        options: { temperature: 0, num_predict: BENCHMARK_MAX_TOKENS }
+      },
+      () => {
+        firstTokenAt ??= Date.now();
+        return true;
+      },
+      token,
+      completed => { stats = completed; }
+    );
+  } catch (error) {
+    return {
+      model,
+      prompt: prompt.name,
+      totalMs: Date.now() - startTime,
+      error: error instanceof Error ? error.message : String(error)
+    };
+  }
+
+  const endTime = Date.now();
+  const completionTokens = stats?.evalCount;
+  const generationMs = firstTokenAt !== undefined ? endTime - firstTokenAt : 0;
+  return {
+    model,
+    prompt: prompt.name,
+    timeToFirstTokenMs: firstTokenAt !== undefined ? firstTokenAt - startTime : undefined,
+    totalMs: endTime - startTime,
+    completionTokens,
+    tokensPerSecond: completionTokens && generationMs > 0
+      ? Math.round((completionTokens / generationMs) * 10000) / 10
+      : undefined
+  };
+}
+
+/**
+ * Formats results as a markdown table, one row per model and prompt
+ */
+export function formatBenchmarkTable(results: BenchmarkResult[]): string {
+  const format = (value: number | undefined, unit = ''): string => value === undefined ? '-' : `${value}${unit}`;
+  const rows = results.map(result => result.error
+    ? `| ${result.model} | ${result.prompt} | - | - | - | - | ${result.error.replace(/\|/g, '\\|')} |`
+    : `| ${result.model} | ${result.prompt} | ${format(result.timeToFirstTokenMs, ' ms')} | ${format(result.totalMs, ' ms')} | ${format(result.completionTokens)} | ${format(result.tokensPerSecond)} | |`
+  );
+
+  return [
+    '# Model Benchmark',
+    '',
+    '| Model | Prompt | Time to first token | Total latency | Tokens | Tokens/sec | Error |',
+    '| --- | --- | ---: | ---: | ---: | ---: | --- |',
+    ...rows,
+    ''
+  ].join('\n');
+}
@@ -5,6 +5,7 @@
 
 import * as path from 'path';
 import * as fs from 'fs';
+import * as os from 'os';
 import * as vscode from 'vscode';
 import { sanitizeFilePath } from './sanitization';
 
@@ -197,6 +198,23 @@ export function getWorkspaceRelativePath(filePath: string): string | null {
   return null;
 }
 
+/**
+ * Resolves a user-configured path: "~" expands to the home directory and
+ * relative paths are taken from the first workspace folder
+ */
+export function resolveWorkspacePath(filePath: string): string {
+  const trimmed = filePath.trim();
+  if (trimmed === '~' || trimmed.startsWith('~/') || trimmed.startsWith('~\\')) {
+    return path.join(os.homedir(), trimmed.slice(1));
+  }
+  if (path.isAbsolute(trimmed)) {
+    return path.normalize(trimmed);
+  }
+  
+  const root = vscode.workspace.workspaceFolders?.[0]?.uri.fsPath;
+  return path.resolve(root || process.cwd(), trimmed);
+}
+
 /**
  * Validates file extension against allowed types
  */
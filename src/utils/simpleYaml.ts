@@ -0,0 +1,246 @@
+/**
+ * Parser for the subset of YAML used by configuration files
+ *
+ * Supports nested mappings by indentation, block lists of scalars, inline
+ * lists like [a, "b"], quoted and plain scalars, numbers, booleans, null
+ * and # comments. Anchors, multi-line strings and flow mappings are not
+ * supported and fail with the line they appear on, and so do keys such as
+ * __proto__ that would change the prototype of the parsed objects.
+ */
+
+/**
+ * Thrown when a document cannot be parsed, with the line of the problem
+ */
+export class YamlParseError extends Error {
+  constructor(message: string, readonly line: number) {
+    super(`line ${line}: ${message}`);
+    this.name = 'YamlParseError';
+  }
+}
+
+interface YamlLine {
+  indent: number;
+  text: string;
+  line: number;
+}
+
+const RESERVED_KEYS = new Set(['__proto__', 'constructor', 'prototype']);
+
+const KEY_PATTERN = /^("(?:[^"\\]|\\.)*"|'(?:[^']|'')*'|[^\s"'#:][^:]*?)\s*:(?:\s+(.*))?$/;
+
+/**
+ * Parses a document whose top level is a mapping
+ */
+export function parseSimpleYaml(source: string): Record<string, unknown> {
+  const lines = tokenize(source);
+  if (lines.length === 0) {
+    return {};
+  }
+
+  const [value, next] = parseBlock(lines, 0, lines[0].indent);
+  if (next < lines.length) {
+    throw new YamlParseError('unexpected indentation', lines[next].line);
+  }
+  if (!value || typeof value !== 'object' || Array.isArray(value)) {
+    throw new YamlParseError('expected a mapping at the top level', lines[0].line);
+  }
+  return value as Record<string, unknown>;
+}
+
+function tokenize(source: string): YamlLine[] {
+  const lines: YamlLine[] = [];
+
+  source.split(/\r?\n/).forEach((raw, index) => {
+    const line = index + 1;
+    const text = stripComment(raw).trimEnd();
+    if (!text.trim() || text.trim() === '---') {
+      return;
+    }
+
+    const indentation = text.match(/^[ \t]*/)![0];
+    if (indentation.includes('\t')) {
+      throw new YamlParseError('tabs are not allowed for indentation', line);
+    }
+    lines.push({ indent: indentation.length, text: text.trim(), line });
+  });
+
+  return lines;
+}
+
+/**
+ * Cuts a # comment that is outside quotes and starts the line or follows whitespace
+ */
+function stripComment(line: string): string {
+  let quote: string | undefined;
+  for (let i = 0; i < line.length; i++) {
+    const char = line[i];
+    if (quote) {
+      if (char === '\\' && quote === '"') {
+        i++;
+      } else if (char === quote) {
+        quote = undefined;
+      }
+    } else if (char === '"' || char === "'") {
+      quote = char;
+    } else if (char === '#' && (i === 0 || /\s/.test(line[i - 1]))) {
+      return line.substring(0, i);
+    }
+  }
+  return line;
+}
+
+function parseBlock(lines: YamlLine[], start: number, indent: number): [unknown, number] {
+  return isListItem(lines[start].text)
+    ? parseList(lines, start, indent)
+    : parseMapping(lines, start, indent);
+}
+
+function isListItem(text: string): boolean {
+  return text === '-' || text.startsWith('- ');
+}
+
+function parseMapping(lines: YamlLine[], start: number, indent: number): [Record<string, unknown>, number] {
+  const mapping: Record<string, unknown> = {};
+  let i = start;
+
+  while (i < lines.length && lines[i].indent === indent) {
+    const { text, line } = lines[i];
+    const match = text.match(KEY_PATTERN);
+    if (!match || isListItem(text)) {
+      throw new YamlParseError(`expected "key: value", got "${text}"`, line);
+    }
+
+    const key = String(parseScalar(match[1], line));
+    if (RESERVED_KEYS.has(key)) {
+      throw new YamlParseError(`reserved key "${key}"`, line);
+    }
+    if (Object.prototype.hasOwnProperty.call(mapping, key)) {
+      throw new YamlParseError(`duplicate key "${key}"`, line);
+    }
+
+    i++;
+    if (match[2] !== undefined && match[2] !== '') {
+      mapping[key] = parseValue(match[2], line);
+    } else if (i < lines.length && lines[i].indent > indent) {
+      const [value, next] = parseBlock(lines, i, lines[i].indent);
+      mapping[key] = value;
+      i = next;
+    } else {
+      mapping[key] = null;
+    }
+  }
+
+  if (i < lines.length && lines[i].indent > indent) {
+    throw new YamlParseError('unexpected indentation', lines[i].line);
+  }
+  return [mapping, i];
+}
+
+function parseList(lines: YamlLine[], start: number, indent: number): [unknown[], number] {
+  const list: unknown[] = [];
+  let i = start;
+
+  while (i < lines.length && lines[i].indent === indent && isListItem(lines[i].text)) {
+    const { text, line } = lines[i];
+    const item = text.substring(1).trim();
+    i++;
+    if (item) {
+      if (KEY_PATTERN.test(item) && !/^["'[]/.test(item)) {
+        throw new YamlParseError('mappings inside lists are not supported', line);
+      }
+      list.push(parseValue(item, line));
+    } else if (i < lines.length && lines[i].indent > indent) {
+      const [value, next] = parseBlock(lines, i, lines[i].indent);
+      list.push(value);
+      i = next;
+    } else {
+      list.push(null);
+    }
+  }
+
+  if (i < lines.length && lines[i].indent > indent) {
+    throw new YamlParseError('unexpected indentation', lines[i].line);
+  }
+  return [list, i];
+}
+
+function parseValue(text: string, line: number): unknown {
+  if (text.startsWith('[')) {
+    if (!text.endsWith(']')) {
+      throw new YamlParseError('unclosed inline list', line);
+    }
+    const body = text.slice(1, -1).trim();
+    return body ? splitInlineList(body, line).map(item => parseScalar(item, line)) : [];
+  }
+  if (text.startsWith('{') || text.startsWith('&') || text.startsWith('*') || text === '|' || text === '>') {
+    throw new YamlParseError(`unsupported value "${text}"`, line);
+  }
+  return parseScalar(text, line);
+}
+
+function splitInlineList(body: string, line: number): string[] {
+  const items: string[] = [];
+  let quote: string | undefined;
+  let current = '';
+
+  for (let i = 0; i < body.length; i++) {
+    const char = body[i];
+    if (quote) {
+      if (char === '\\' && quote === '"') {
+        current += char + (body[++i] ?? '');
+        continue;
+      }
+      if (char === quote) {
+        quote = undefined;
+      }
+    } else if (char === '"' || char === "'") {
+      quote = char;
+    } else if (char === ',') {
+      items.push(current.trim());
+      current = '';
+      continue;
+    } else if (char === '[' || char === '{') {
+      throw new YamlParseError('nested inline collections are not supported', line);
+    }
+    current += char;
+  }
+
+  if (quote) {
+    throw new YamlParseError('unterminated string', line);
+  }
+  items.push(current.trim());
+  return items;
+}
+
+function parseScalar(text: string, line: number): unknown {
+  if (text.startsWith('"')) {
+    if (text.length < 2 || !text.endsWith('"')) {
+      throw new YamlParseError('unterminated string', line);
+    }
+    try {
+      return JSON.parse(text);
+    } catch {
+      throw new YamlParseError(`invalid string ${text}`, line);
+    }
+  }
+  if (text.startsWith("'")) {
+    if (text.length < 2 || !text.endsWith("'")) {
+      throw new YamlParseError('unterminated string', line);
+    }
+    return text.slice(1, -1).replace(/''/g, "'");
+  }
+
+  if (/^(true|True|TRUE)$/.test(text)) {
+    return true;
+  }
+  if (/^(false|False|FALSE)$/.test(text)) {
+    return false;
+  }
+  if (/^(null|Null|NULL|~)$/.test(text)) {
+    return null;
+  }
+  if (/^[-+]?(\d[\d_]*)?\.?\d+([eE][-+]?\d+)?$/.test(text)) {
+    return Number(text.replace(/_/g, ''));
+  }
+  return text;
+}
@@ -7,6 +7,7 @@ import * as vscode from 'vscode';
 import {
     ConfigValidationOptions, ValidationError, ValidationResult
 } from '../schemas/ValidationSchemas';
+import { CompletionProfile } from '../services/interfaces/ICompletionService';
 
 // Removed unused import
 
@@ -17,13 +18,67 @@ export interface ExtensionConfig {
   defaultModel?: string;
   apiHost?: string;
   enableInlineCompletion?: boolean;
+  autoPullModels?: boolean;
+  autoPullTimeout?: number;
+  keepAlive?: string;
+  warmUpOnStartup?: boolean;
+  warmUpOnConfigChange?: boolean;
+  http?: {
+    verifyTls?: boolean;
+    connectTimeout?: number;
+    maxIdleConnections?: number;
+    headers?: Record<string, string>;
+  };
+  logLevel?: 'error' | 'warn' | 'info' | 'debug';
   maxMessageHistory?: number;
   maxMessageLength?: number;
   completionCacheSize?: number;
   completion?: {
     maxTokens?: number;
+    inlineMaxTokens?: number;
+    singleLineInline?: boolean;
+    candidates?: number;
+    seed?: number | null;
     temperature?: number;
     contextWindow?: number;
+    autoContextWindow?: boolean;
+    contextWindowStep?: number;
+    stopSequences?: string[];
+    fallbackModels?: string[];
+    enableFim?: boolean;
+    echoMode?: boolean;
+    trimTrailingWhitespace?: boolean;
+    balanceBrackets?: boolean;
+    truncateAtSuffix?: boolean;
+    confineToComment?: boolean;
+    reindent?: boolean;
+    stripMarkdown?: boolean;
+    stripEcho?: boolean;
+    promptTemplateFile?: string;
+    endpoint?: 'generate' | 'chat';
+    cacheTTL?: number;
+    maxRetries?: number;
+    maxConcurrentRequests?: number;
+    maxQueuedRequests?: number;
+    retryBaseDelay?: number;
+    timeout?: number;
+    shutdownGracePeriod?: number;
+    diskCache?: {
+      enabled?: boolean;
+      directory?: string;
+      maxSizeMB?: number;
+      ttl?: number;
+    };
+    profiles?: Record<string, CompletionProfile>;
+    crossFileContextTokens?: number;
+    contextLinesBefore?: number;
+    contextLinesAfter?: number;
+    fileHeader?: boolean;
+    inspectPrompt?: boolean;
+  };
+  metrics?: {
+    enabled?: boolean;
+    textfilePath?: string;
   };
   memory?: {
     enableMonitoring?: boolean;
@@ -49,6 +104,54 @@ export const configurationRules: Record<string, ConfigValidationOptions> = {
     required: false,
     pattern: /^https?:\/\/.+/
   },
+  'ollama.logLevel': {
+    type: 'string',
+    required: false,
+    pattern: /^(error|warn|info|debug)$/
+  },
+  'ollama.autoPullModels': {
+    type: 'boolean',
+    required: false
+  },
+  'ollama.autoPullTimeout': {
+    type: 'number',
+    required: false,
+    min: 10000
+  },
+  'ollama.keepAlive': {
+    type: 'string',
+    required: false,
+    // Seconds, or a Go duration such as "30m" or "1h30m"
+    pattern: /^(-?\d+|(-?\d+(\.\d+)?(ms|s|m|h))+)?$/
+  },
+  'ollama.warmUpOnStartup': {
+    type: 'boolean',
+    required: false
+  },
+  'ollama.warmUpOnConfigChange': {
+    type: 'boolean',
+    required: false
+  },
+  'ollama.http.verifyTls': {
+    type: 'boolean',
+    required: false
+  },
+  'ollama.http.connectTimeout': {
+    type: 'number',
+    required: false,
+    min: 0,
+    max: 300000
+  },
+  'ollama.http.maxIdleConnections': {
+    type: 'number',
+    required: false,
+    min: 1,
+    max: 64
+  },
+  'ollama.http.headers': {
+    type: 'object',
+    required: false
+  },
   'ollama.enableInlineCompletion': {
     type: 'boolean',
     required: false
@@ -59,6 +162,46 @@ export const configurationRules: Record<string, ConfigValidationOptions> = {
     min: 1,
     max: 16384
   },
+  'ollama.completion.seed': {
+    type: 'number',
+    required: false
+  },
+  'ollama.completion.contextLinesBefore': {
+    type: 'number',
+    required: false,
+    min: 0,
+    max: 10000
+  },
+  'ollama.completion.contextLinesAfter': {
+    type: 'number',
+    required: false,
+    min: 0,
+    max: 10000
+  },
+  'ollama.completion.fileHeader': {
+    type: 'boolean',
+    required: false
+  },
+  'ollama.completion.inspectPrompt': {
+    type: 'boolean',
+    required: false
+  },
+  'ollama.completion.candidates': {
+    type: 'number',
+    required: false,
+    min: 1,
+    max: 5
+  },
+  'ollama.completion.inlineMaxTokens': {
+    type: 'number',
+    required: false,
+    min: 1,
+    max: 16384
+  },
+  'ollama.completion.singleLineInline': {
+    type: 'boolean',
+    required: false
+  },
   'ollama.completion.temperature': {
     type: 'number',
     required: false,
@@ -71,6 +214,149 @@ export const configurationRules: Record<string, ConfigValidationOptions> = {
     min: 1,
     max: 131072
   },
+  'ollama.completion.autoContextWindow': {
+    type: 'boolean',
+    required: false
+  },
+  'ollama.completion.contextWindowStep': {
+    type: 'number',
+    required: false,
+    min: 0,
+    max: 131072
+  },
+  'ollama.completion.stopSequences': {
+    type: 'array',
+    required: false
+  },
+  'ollama.completion.fallbackModels': {
+    type: 'array',
+    required: false
+  },
+  'ollama.completion.enableFim': {
+    type: 'boolean',
+    required: false
+  },
+  'ollama.completion.trimTrailingWhitespace': {
+    type: 'boolean',
+    required: false
+  },
+  'ollama.completion.balanceBrackets': {
+    type: 'boolean',
+    required: false
+  },
+  'ollama.completion.truncateAtSuffix': {
+    type: 'boolean',
+    required: false
+  },
+  'ollama.completion.confineToComment': {
+    type: 'boolean',
+    required: false
+  },
+  'ollama.completion.reindent': {
+    type: 'boolean',
+    required: false
+  },
+  'ollama.completion.echoMode': {
+    type: 'boolean',
+    required: false
+  },
+  'ollama.completion.stripMarkdown': {
+    type: 'boolean',
+    required: false
+  },
+  'ollama.completion.stripEcho': {
+    type: 'boolean',
+    required: false
+  },
+  'ollama.completion.maxRetries': {
+    type: 'number',
+    required: false,
+    min: 0,
+    max: 10
+  },
+  'ollama.completion.retryBaseDelay': {
+    type: 'number',
+    required: false,
+    min: 0,
+    max: 10000
+  },
+  'ollama.completion.maxConcurrentRequests': {
+    type: 'number',
+    required: false,
+    min: 1,
+    max: 32
+  },
+  'ollama.completion.maxQueuedRequests': {
+    type: 'number',
+    required: false,
+    min: 0,
+    max: 100
+  },
+  'ollama.completion.promptTemplateFile': {
+    type: 'string',
+    required: false
+  },
+  'ollama.completion.endpoint': {
+    type: 'string',
+    required: false,
+    pattern: /^(generate|chat)$/
+  },
+  'ollama.completion.cacheTTL': {
+    type: 'number',
+    required: false,
+    min: 1000,
+    max: 86400000 // 24 hours max
+  },
+  'ollama.completion.diskCache.enabled': {
+    type: 'boolean',
+    required: false
+  },
+  'ollama.completion.diskCache.directory': {
+    type: 'string',
+    required: false
+  },
+  'ollama.completion.diskCache.maxSizeMB': {
+    type: 'number',
+    required: false,
+    min: 1,
+    max: 10240
+  },
+  'ollama.completion.diskCache.ttl': {
+    type: 'number',
+    required: false,
+    min: 60000,
+    max: 31536000000 // 1 year max
+  },
+  'ollama.completion.timeout': {
+    type: 'number',
+    required: false,
+    min: 0
+  },
+  'ollama.completion.shutdownGracePeriod': {
+    type: 'number',
+    required: false,
+    min: 0,
+    max: 5000
+  },
+  'ollama.completion.profiles': {
+    type: 'object',
+    required: false
+  },
+  'ollama.completion.crossFileContextTokens': {
+    type: 'number',
+    required: false,
+    min: 0,
+    max: 32768
+  },
+  'ollama.metrics.enabled': {
+    type: 'boolean',
+    required: false
+  },
+  'ollama.metrics.textfilePath': {
+    type: 'string',
+    required: false,
+    pattern: /^(.*\.prom)?$/
+  },
   'ollama.maxMessageHistory': {
     type: 'number',
     required: false,
@@ -363,12 +649,52 @@ export class ConfigurationValidator {
     const defaults: Record<string, any> = {
       apiHost: 'http://localhost:11434',
       enableInlineCompletion: true,
+      autoPullModels: false,
+      autoPullTimeout: 600000,
+      keepAlive: '',
+      warmUpOnStartup: false,
+      warmUpOnConfigChange: true,
+      'http.verifyTls': true,
+      'http.connectTimeout': 10000,
+      'http.maxIdleConnections': 4,
+      logLevel: 'info',
       maxMessageHistory: 100,
       maxMessageLength: 10000,
       completionCacheSize: 100,
       'completion.maxTokens': 150,
+      'completion.inlineMaxTokens': 32,
+      'completion.singleLineInline': false,
+      'completion.candidates': 1,
       'completion.temperature': 0.7,
       'completion.contextWindow': 2048,
+      'completion.autoContextWindow': false,
+      'completion.contextWindowStep': 0,
+      'completion.enableFim': true,
+      'completion.echoMode': false,
+      'completion.trimTrailingWhitespace': true,
+      'completion.balanceBrackets': true,
+      'completion.truncateAtSuffix': true,
+      'completion.confineToComment': true,
+      'completion.reindent': true,
+      'completion.stripMarkdown': true,
+      'completion.stripEcho': true,
+      'completion.endpoint': 'generate',
+      'completion.cacheTTL': 300000,
+      'completion.diskCache.enabled': false,
+      'completion.diskCache.maxSizeMB': 50,
+      'completion.diskCache.ttl': 604800000,
+      'completion.maxRetries': 2,
+      'completion.maxConcurrentRequests': 2,
+      'completion.maxQueuedRequests': 8,
+      'completion.retryBaseDelay': 250,
+      'completion.timeout': 10000,
+      'completion.shutdownGracePeriod': 2000,
+      'completion.crossFileContextTokens': 512,
+      'completion.contextLinesBefore': 80,
+      'completion.contextLinesAfter': 40,
+      'completion.fileHeader': false,
+      'completion.inspectPrompt': false,
+      'metrics.enabled': false,
       'memory.enableMonitoring': false,
       'memory.monitoringInterval': 30000,
       'memory.warningThresholdMB': 200,
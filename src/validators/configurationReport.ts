@@ -0,0 +1,161 @@
+/**
+ * Pass/fail report covering the whole configuration
+ */
+
+import * as vscode from 'vscode';
+import * as fs from 'fs';
+import * as path from 'path';
+import { IOllamaApiService } from '../services/interfaces/IOllamaApiService';
+import { CompletionProfileMap, getConfiguredModels, getProfileModels, sanitizeProfile } from '../config/completionProfiles';
+import { parsePromptTemplate } from '../inlineCompletionProvider/promptTemplate';
+import { parseSimpleYaml } from '../utils/simpleYaml';
+import { resolveWorkspacePath } from '../utils/pathSecurity';
+import { getConfigurationValidator } from './ConfigurationValidator';
+
+/**
+ * Outcome of one check
+ */
+export interface ConfigurationCheck {
+  name: string;
+  passed: boolean;
+  detail?: string;
+}
+
+/**
+ * Checks in the order they ran, and whether all of them passed
+ */
+export interface ConfigurationReport {
+  passed: boolean;
+  checks: ConfigurationCheck[];
+}
+
+/**
+ * Checks the settings against their allowed ranges, every completion
+ * profile and project file for values that would be ignored, that prompt
+ * templates parse, and that every referenced model is pulled in Ollama
+ */
+export async function buildConfigurationReport(
+  apiService: IOllamaApiService,
+  selectedModel?: string
+): Promise<ConfigurationReport> {
+  const checks: ConfigurationCheck[] = [];
+  const config = vscode.workspace.getConfiguration('ollama');
+  const templates = new Map<string, string>();
+  const models = new Set<string>(selectedModel ? [selectedModel] : []);
+
+  // Settings
+  const validation = await getConfigurationValidator().validateConfiguration();
+  if (validation.isValid) {
+    checks.push({ name: 'Settings', passed: true, detail: 'All values are valid' });
+  }
+  for (const error of validation.errors) {
+    checks.push({ name: `Setting ${error.field}`, passed: false, detail: error.message });
+  }
+
+  const profiles = config.get<CompletionProfileMap>('completion.profiles', {});
+  checks.push(...Object.entries(profiles || {}).map(([key, value]) => checkProfile(`Profile ${key}`, value)));
+  getConfiguredModels(config.get<string>('defaultModel', ''), profiles).forEach(model => models.add(model));
+  (config.get<string[]>('completion.fallbackModels', []) || []).forEach(model => models.add(model));
+  collectTemplates(templates, config.get<string>('completion.promptTemplateFile', ''), resolveWorkspacePath);
+  Object.values(profiles || {}).forEach(profile => {
+    collectTemplates(templates, sanitizeProfile(profile)?.promptTemplateFile, resolveWorkspacePath);
+  });
+
+  // Project files
+  const projectFiles = await vscode.workspace.findFiles('**/.ollama-copilot.{yaml,yml}', '**/node_modules/**');
+  for (const uri of projectFiles) {
+    const file = uri.fsPath;
+    const name = vscode.workspace.asRelativePath(uri);
+    let raw: Record<string, unknown>;
+    try {
+      raw = parseSimpleYaml(fs.readFileSync(file, 'utf8'));
+    } catch (error) {
+      checks.push({ name: `Project file ${name}`, passed: false, detail: error instanceof Error ? error.message : String(error) });
+      continue;
+    }
+
+    const { profiles: projectProfiles, ...settings } = raw;
+    const profileMap = projectProfiles && typeof projectProfiles === 'object' && !Array.isArray(projectProfiles)
+      ? projectProfiles as CompletionProfileMap
+      : {};
+    const resolveRelative = (template: string): string => path.resolve(path.dirname(file), template);
+    checks.push(
+      checkProfile(`Project file ${name}`, settings),
+      ...Object.entries(profileMap).map(([key, value]) => checkProfile(`Project file ${name}, profile ${key}`, value))
+    );
+
+    const project = sanitizeProfile(settings);
+    [project?.model, ...(project?.fallbackModels || []), ...getProfileModels(profileMap)]
+      .forEach(model => model && models.add(model));
+    collectTemplates(templates, project?.promptTemplateFile, resolveRelative);
+    Object.values(profileMap).forEach(profile => {
+      collectTemplates(templates, sanitizeProfile(profile)?.promptTemplateFile, resolveRelative);
+    });
+  }
+
+  // Prompt templates
+  for (const [resolved, file] of templates) {
+    try {
+      parsePromptTemplate(fs.readFileSync(resolved, 'utf8'));
+      checks.push({ name: `Prompt template ${file}`, passed: true });
+    } catch (error) {
+      checks.push({ name: `Prompt template ${file}`, passed: false, detail: error instanceof Error ? error.message : String(error) });
+    }
+  }
+
+  // Models
+  const health = await apiService.checkHealth([...models]);
+  if (health.ollamaVersion === undefined) {
+    checks.push({ name: 'Ollama', passed: false, detail: health.error });
+  } else {
+    checks.push({ name: 'Ollama', passed: true, detail: `Version ${health.ollamaVersion} at ${health.host}` });
+    for (const model of models) {
+      const missing = health.missingModels.includes(model);
+      checks.push({ name: `Model ${model}`, passed: !missing, detail: missing ? 'Not pulled' : undefined });
+    }
+  }
+
+  return { passed: checks.every(check => check.passed), checks };
+}
+
+/**
+ * Formats a report as markdown, failures first
+ */
+export function formatConfigurationReport(report: ConfigurationReport): string {
+  const ordered = [...report.checks.filter(check => !check.passed), ...report.checks.filter(check => check.passed)];
+  const failed = report.checks.length - report.checks.filter(check => check.passed).length;
+  return [
+    '# Configuration Validation',
+    '',
+    report.passed ? `**PASS**: all ${report.checks.length} checks passed` : `**FAIL**: ${failed} of ${report.checks.length} checks failed`,
+    '',
+    ...ordered.map(check => `- ${check.passed ? 'PASS' : 'FAIL'} ${check.name}${check.detail ? `: ${check.detail}` : ''}`),
+    ''
+  ].join('\n');
+}
+
+/**
+ * Reports profile fields that are unknown or out of range, since profiles
+ * silently drop them when completions run
+ */
+function checkProfile(name: string, value: unknown): ConfigurationCheck {
+  if (!value || typeof value !== 'object' || Array.isArray(value)) {
+    return { name, passed: false, detail: 'Must be a mapping of completion settings' };
+  }
+  const kept = sanitizeProfile(value) || {};
+  const ignored = Object.keys(value).filter(field => !(field in kept));
+  return ignored.length > 0
+    ? { name, passed: false, detail: `Invalid or unknown fields are ignored: ${ignored.join(', ')}` }
+    : { name, passed: true };
+}
+
+/**
+ * Adds a template file, keyed by its resolved path, to the files to parse
+ */
+function collectTemplates(templates: Map<string, string>, file: string | undefined, resolve: (file: string) => string): void {
+  const trimmed = file?.trim();
+  if (trimmed) {
+    const resolved = path.isAbsolute(trimmed) || trimmed.startsWith('~') ? resolveWorkspacePath(trimmed) : resolve(trimmed);
+    templates.set(resolved, trimmed);
+  }
+}